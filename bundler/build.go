@@ -1,6 +1,11 @@
 package bundler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"time"
 
@@ -10,7 +15,7 @@ import (
 
 //go:generate faux --interface EntryResolver --output fakes/entry_resolver.go
 type EntryResolver interface {
-	Resolve([]packit.BuildpackPlanEntry) packit.BuildpackPlanEntry
+	Resolve(config BuildpackConfig, entries []packit.BuildpackPlanEntry) (packit.BuildpackPlanEntry, error)
 }
 
 //go:generate faux --interface DependencyManager --output fakes/dependency_manager.go
@@ -21,75 +26,430 @@ type DependencyManager interface {
 
 //go:generate faux --interface BuildPlanRefinery --output fakes/build_plan_refinery.go
 type BuildPlanRefinery interface {
-	BillOfMaterial(dependency postal.Dependency) packit.BuildpackPlan
+	BillOfMaterial(dependency Dependency, workingDir string) (packit.BuildpackPlan, error)
 }
 
-func Build(entries EntryResolver, dependencies DependencyManager, planRefinery BuildPlanRefinery, logger LogEmitter, clock Clock) packit.BuildFunc {
+//go:generate faux --interface SBOMGenerator --output fakes/sbom_generator.go
+type SBOMGenerator interface {
+	Generate(dependency Dependency, layerPath string, now time.Time) error
+}
+
+//go:generate faux --interface BindingResolver --output fakes/binding_resolver.go
+type BindingResolver interface {
+	Resolve(typ string) ([]Binding, error)
+}
+
+//go:generate faux --interface InstallProcess --output fakes/install_process.go
+type InstallProcess interface {
+	RubyVersion() (string, error)
+	VerifyBundlerVersion(bundlerLayerPath, version string) error
+	Execute(workingDir, bundlerLayerPath, gemsLayerPath string, env map[string]string) error
+}
+
+func Build(entries EntryResolver, dependencies DependencyManager, installProcess InstallProcess, planRefinery BuildPlanRefinery, sbomGenerator SBOMGenerator, bindings BindingResolver, deprecationPolicy DeprecationPolicy, logger LogEmitter, clock Clock) packit.BuildFunc {
 	return func(context packit.BuildContext) (packit.BuildResult, error) {
+		// Credentials are read before anything is logged so that they are
+		// redacted from all of the output of the build.
+		var credentialBindings []Binding
+		for _, typ := range []string{BundlerBindingType, GemCredentialsBindingType} {
+			resolved, err := bindings.Resolve(typ)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			credentialBindings = append(credentialBindings, resolved...)
+		}
+
+		credentials, err := parseGemCredentials(credentialBindings)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		credentialEnv := map[string]string{}
+		for _, credential := range credentials {
+			logger.Redact(credential.secrets()...)
+			credentialEnv[credential.EnvName()] = credential.Value
+		}
+
+		// The retry settings are used by the Transport, which only sees them
+		// when something is downloaded, so they are validated here to fail the
+		// build even when every layer is reused.
+		_, err = ParseRetryConfig(os.Getenv(DependencyAttemptsEnv), os.Getenv(DependencyTimeoutEnv))
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
 		logger.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
 		logger.Process("Resolving Bundler version")
 
-		entry := entries.Resolve(context.Plan.Entries)
+		config, err := ParseBuildpackConfig(filepath.Join(context.CNBPath, "buildpack.toml"))
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		entry, err := entries.Resolve(config, withEnvironmentEntry(context.Plan.Entries))
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// Ruby is only needed to install gems, so a build without it only loses
+		// the filtering of versions by Ruby compatibility.
+		installedRuby, rubyErr := installProcess.RubyVersion()
+		if rubyErr != nil {
+			installedRuby = ""
+		}
+		ruby, rubySource := rubyVersion(installedRuby)
+
+		// The trace starts from the requested version so that it shows which
+		// versions the Ruby filter below excludes, and why.
+		if logger.Debugging() {
+			for _, line := range resolutionTrace(config, entry.Name, entry.Version, context.Stack, ruby) {
+				logger.Debug("%s", line)
+			}
+		}
+
+		version, incompatible, err := rubyCompatibleVersion(config, entry.Name, entry.Version, context.Stack, ruby)
+		if len(incompatible) > 0 {
+			logger.IncompatibleDependencies(ruby, rubySource, incompatible)
+		}
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 
-		dependency, err := dependencies.Resolve(filepath.Join(context.CNBPath, "buildpack.toml"), entry.Name, entry.Version, context.Stack)
+		dependency, err := resolveDependency(dependencies, config, filepath.Join(context.CNBPath, "buildpack.toml"), entry.Name, version, context.Stack)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
 		logger.SelectedDependency(entry, dependency, clock.Now())
 
-		bundlerLayer, err := context.Layers.Get(Bundler, packit.LaunchLayer)
+		err = deprecationPolicy.Check(dependency)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
+		mappings, err := bindings.Resolve(DependencyMappingBindingType)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		uri, binding, ok, err := mapDependencyURI(mappings, dependency.SHA256, context.CNBPath)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if ok {
+			logger.DependencyMapping(binding, dependency, uri)
+			dependency.URI = uri
+		}
+
+		bundlerLayer, err := context.Layers.Get(Bundler)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// The flags are driven by the plan entries so that bundler only ends up
+		// in the app image when one of them asks for it at launch.
+		bundlerLayer.Launch = entry.Metadata["launch"] == true
 		bundlerLayer.Build = entry.Metadata["build"] == true
 		bundlerLayer.Cache = entry.Metadata["build"] == true
 
-		bom := planRefinery.BillOfMaterial(postal.Dependency{
-			ID:      dependency.ID,
-			Name:    dependency.Name,
-			SHA256:  dependency.SHA256,
-			Stacks:  dependency.Stacks,
-			URI:     dependency.URI,
-			Version: dependency.Version,
-		})
-
-		cachedSHA, ok := bundlerLayer.Metadata[DepKey].(string)
-		if ok && cachedSHA == dependency.SHA256 {
+		buildpackDependency := parseDependency(config, dependency)
+
+		bom, err := planRefinery.BillOfMaterial(buildpackDependency, context.WorkingDir)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		var builtAt time.Time
+
+		// The stack and buildpack version are part of the key so that upgrading
+		// either of them reinstalls Bundler.
+		bundlerMetadata := map[string]string{
+			DepKey:              dependency.SHA256,
+			StackKey:            context.Stack,
+			BuildpackVersionKey: context.BuildpackInfo.Version,
+		}
+
+		// A reproducible build pins the build time, so a layer cached with a
+		// different time is rebuilt rather than reused with that time.
+		if clock.Reproducible() {
+			timestamp, err := clock.Timestamp()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			bundlerMetadata[BuiltAtKey] = timestamp.Format(time.RFC3339Nano)
+		}
+
+		logger.ReuseInputs(Bundler, bundlerLayer.Metadata, bundlerMetadata)
+		reasons := layerReuseDiff(bundlerLayer.Metadata, bundlerMetadata)
+		if len(reasons) == 0 {
 			logger.Process("Reusing cached layer %s", bundlerLayer.Path)
 			logger.Break()
 
+			// The original build time is kept so that regenerating the SBOM for a
+			// reused layer does not change its contents.
+			cachedBuiltAt, _ := bundlerLayer.Metadata[BuiltAtKey].(string)
+			builtAt, err = time.Parse(time.RFC3339Nano, cachedBuiltAt)
+			if err != nil {
+				builtAt, err = clock.Timestamp()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+		} else {
+			logger.Process("Executing build process")
+			logger.LayerNotReused(Bundler, reasons)
+
+			err = bundlerLayer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			builtAt, err = clock.Timestamp()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			bundlerLayer.Metadata = map[string]interface{}{
+				BuiltAtKey: builtAt.Format(time.RFC3339Nano),
+			}
+			for key, value := range bundlerMetadata {
+				bundlerLayer.Metadata[key] = value
+			}
+
+			logger.Subprocess("Installing Bundler %s", dependency.Version)
+			then := clock.Now()
+			err = dependencies.Install(dependency, context.CNBPath, bundlerLayer.Path)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			// bundle --version runs on ruby, which a build that only provides
+			// Bundler may not have.
+			if rubyErr != nil {
+				logger.Action("Skipping bundle --version check: ruby is not available")
+			} else {
+				err = installProcess.VerifyBundlerVersion(bundlerLayer.Path, dependency.Version)
+				if err != nil {
+					return packit.BuildResult{}, logger.RedactError(err)
+				}
+			}
+			logger.Completed(time.Since(then))
+
+			size, err := directorySize(bundlerLayer.Path)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			logger.LayerSize(Bundler, size)
+			logger.Break()
+		}
+
+		err = sbomGenerator.Generate(buildpackDependency, bundlerLayer.Path, builtAt)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		// Extracted files keep the mtimes from the archive and generated ones get
+		// the current time, so both are pinned to make the layer reproducible.
+		if clock.Reproducible() {
+			err = setModTimes(bundlerLayer.Path, builtAt)
+			if err != nil {
+				return packit.BuildResult{}, fmt.Errorf("failed to set modification times: %w", err)
+			}
+		}
+
+		setEnvironment(&bundlerLayer)
+
+		layers := []packit.Layer{bundlerLayer}
+
+		// Credentials live in a layer of their own that is neither cached nor
+		// exported, keeping them out of the cache and the app image while still
+		// exposing them to the buildpacks that follow.
+		if len(credentials) > 0 {
+			credentialsLayer, err := context.Layers.Get(GemCredentials, packit.BuildLayer)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			err = credentialsLayer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			for _, credential := range credentials {
+				logger.GemCredential(credential)
+				credentialsLayer.BuildEnv.Override(credential.EnvName(), credential.Value)
+			}
+
+			layers = append(layers, credentialsLayer)
+		}
+
+		install, err := bundleInstallEnabled()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		gemfileLockSHA, err := fileSHA256(filepath.Join(context.WorkingDir, GemfileLockSource))
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if gemfileLockSHA != "" && !install {
+			logger.Process("Skipping bundle install as %s is false", BundleInstallEnv)
+			logger.Break()
+		}
+
+		// Gems are only installed for apps with a Gemfile.lock since it is the
+		// key used to decide whether the cached gems layer can be reused.
+		if gemfileLockSHA == "" || !install {
 			return packit.BuildResult{
 				Plan:   bom,
-				Layers: []packit.Layer{bundlerLayer},
+				Layers: layers,
 			}, nil
 		}
 
-		logger.Process("Executing build process")
+		if rubyErr != nil {
+			return packit.BuildResult{}, logger.RedactError(rubyErr)
+		}
 
-		err = bundlerLayer.Reset()
+		gemsLayer, err := context.Layers.Get(Gems, packit.LaunchLayer, packit.BuildLayer, packit.CacheLayer)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		bundlerLayer.Metadata = map[string]interface{}{
-			DepKey:     dependency.SHA256,
-			"built_at": clock.Now().Format(time.RFC3339Nano),
+		gemsMetadata := map[string]string{
+			GemfileLockKey:    gemfileLockSHA,
+			BundlerVersionKey: dependency.Version,
+			RubyVersionKey:    installedRuby,
+		}
+		if timestamp, ok := bundlerMetadata[BuiltAtKey]; ok {
+			gemsMetadata[BuiltAtKey] = timestamp
 		}
 
-		logger.Subprocess("Installing Bundler %s", dependency.Version)
-		then := clock.Now()
-		err = dependencies.Install(dependency, context.CNBPath, bundlerLayer.Path)
-		if err != nil {
-			return packit.BuildResult{}, err
+		logger.ReuseInputs(Gems, gemsLayer.Metadata, gemsMetadata)
+		reasons = layerReuseDiff(gemsLayer.Metadata, gemsMetadata)
+		if len(reasons) == 0 {
+			logger.Process("Reusing cached layer %s", gemsLayer.Path)
+			logger.Break()
+		} else {
+			logger.Process("Executing bundle install process")
+			logger.LayerNotReused(Gems, reasons)
+
+			err = gemsLayer.Reset()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			gemsBuiltAt, err := clock.Timestamp()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			gemsLayer.Metadata = map[string]interface{}{
+				BuiltAtKey: gemsBuiltAt.Format(time.RFC3339Nano),
+			}
+			for key, value := range gemsMetadata {
+				gemsLayer.Metadata[key] = value
+			}
+
+			logger.Subprocess("Running 'bundle install'")
+			then := clock.Now()
+			// The error carries the output of bundle install, which packit
+			// prints without going through the LogEmitter.
+			err = installProcess.Execute(context.WorkingDir, bundlerLayer.Path, gemsLayer.Path, credentialEnv)
+			if err != nil {
+				return packit.BuildResult{}, logger.RedactError(err)
+			}
+			logger.Completed(time.Since(then))
+			logger.Break()
 		}
-		logger.Action("Completed in %s", time.Since(then).Round(time.Millisecond))
-		logger.Break()
+
+		gemsLayer.SharedEnv.Default("BUNDLE_PATH", gemsLayer.Path)
 
 		return packit.BuildResult{
 			Plan:   bom,
-			Layers: []packit.Layer{bundlerLayer},
+			Layers: append(layers, gemsLayer),
 		}, nil
 	}
 }
+
+// withEnvironmentEntry adds an entry for BP_BUNDLER_VERSION to the plan
+// entries unless Detect already required it, which it only does for apps with
+// a Gemfile. The entry has no flags, so it only pins the version.
+func withEnvironmentEntry(entries []packit.BuildpackPlanEntry) []packit.BuildpackPlanEntry {
+	version := os.Getenv(EnvironmentSource)
+	if version == "" {
+		return entries
+	}
+
+	for _, entry := range entries {
+		if entry.Metadata["version-source"] == EnvironmentSource {
+			return entries
+		}
+	}
+
+	return append(append([]packit.BuildpackPlanEntry{}, entries...), packit.BuildpackPlanEntry{
+		Name:    Bundler,
+		Version: version,
+		Metadata: map[string]interface{}{
+			"version-source": EnvironmentSource,
+		},
+	})
+}
+
+// fileSHA256 returns the hex-encoded checksum of the file at the given path,
+// or an empty string when that file does not exist.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to checksum %s: %w", filepath.Base(path), err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// setEnvironment makes the gem installed into the layer visible to later
+// buildpacks and to the launched app. Defaults are used for the BUNDLE_*
+// settings so that the app or platform can still override them.
+func setEnvironment(layer *packit.Layer) {
+	layer.SharedEnv.Prepend("PATH", filepath.Join(layer.Path, "bin"), string(os.PathListSeparator))
+	layer.SharedEnv.Prepend("GEM_PATH", layer.Path, string(os.PathListSeparator))
+
+	layer.SharedEnv.Default("BUNDLE_SILENCE_ROOT_WARNING", "1")
+	layer.SharedEnv.Default("BUNDLE_DISABLE_VERSION_CHECK", "true")
+}
+
+// directorySize returns the total size of the regular files under the given
+// directory.
+func directorySize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure layer size: %w", err)
+	}
+
+	return size, nil
+}
@@ -0,0 +1,79 @@
+package bundler
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+// BuildpackConfig is the [metadata] of buildpack.toml that the buildpack reads
+// beyond what postal reads itself. It is parsed once per build and shared by
+// everything that needs it.
+type BuildpackConfig struct {
+	DefaultVersions         map[string]string `toml:"default-versions"`
+	Dependencies            []Dependency      `toml:"dependencies"`
+	VersionSourcePriorities map[string]int    `toml:"version-source-priorities"`
+}
+
+// ParseBuildpackConfig parses the buildpack.toml at the given path.
+func ParseBuildpackConfig(path string) (BuildpackConfig, error) {
+	var buildpack struct {
+		Metadata BuildpackConfig `toml:"metadata"`
+	}
+
+	_, err := toml.DecodeFile(path, &buildpack)
+	if err != nil {
+		return BuildpackConfig{}, fmt.Errorf("failed to parse buildpack.toml: %w", err)
+	}
+
+	return buildpack.Metadata, nil
+}
+
+// constraint returns the constraint that the DependencyManager resolves the
+// given version of a dependency with, following postal: the default version
+// of the dependency replaces an empty or default version, and any version
+// matches when there is no default version either.
+func (c BuildpackConfig) constraint(id, version string) string {
+	if version != "" && version != "default" {
+		return version
+	}
+
+	if defaultVersion := c.DefaultVersions[id]; defaultVersion != "" {
+		return defaultVersion
+	}
+
+	return "*"
+}
+
+// buildpackConfigCache parses each buildpack.toml once for the Transports,
+// which are only given the root of the buildpack.
+type buildpackConfigCache struct {
+	sync.Mutex
+
+	configs map[string]BuildpackConfig
+}
+
+func newBuildpackConfigCache() *buildpackConfigCache {
+	return &buildpackConfigCache{
+		configs: map[string]BuildpackConfig{},
+	}
+}
+
+func (c *buildpackConfigCache) get(path string) (BuildpackConfig, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if config, ok := c.configs[path]; ok {
+		return config, nil
+	}
+
+	config, err := ParseBuildpackConfig(path)
+	if err != nil {
+		return BuildpackConfig{}, err
+	}
+
+	c.configs[path] = config
+
+	return config, nil
+}
@@ -0,0 +1,96 @@
+package bundler_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/cloudfoundry/packit/postal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBuildpackConfig(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		cnbDir string
+		path   string
+	)
+
+	it.Before(func() {
+		var err error
+		cnbDir, err = ioutil.TempDir("", "cnb")
+		Expect(err).NotTo(HaveOccurred())
+
+		path = filepath.Join(cnbDir, "buildpack.toml")
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(cnbDir)).To(Succeed())
+	})
+
+	context("ParseBuildpackConfig", func() {
+		it.Before(func() {
+			err := ioutil.WriteFile(path, []byte(`api = "0.2"
+[buildpack]
+  id = "org.some-org.some-buildpack"
+
+[metadata]
+  [metadata.default-versions]
+    bundler = "2.x.x"
+
+  [metadata.version-source-priorities]
+    "Gemfile.lock" = 5
+
+  [[metadata.dependencies]]
+    id = "bundler"
+    sha256 = "some-sha"
+    stacks = ["*"]
+    uri = "some-uri"
+    version = "2.1.4"
+    licenses = ["MIT"]
+    ruby_versions = ">= 2.3.0"
+`), 0644)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("parses the metadata", func() {
+			config, err := bundler.ParseBuildpackConfig(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(bundler.BuildpackConfig{
+				DefaultVersions: map[string]string{"bundler": "2.x.x"},
+				Dependencies: []bundler.Dependency{
+					{
+						Dependency: postal.Dependency{
+							ID:      "bundler",
+							SHA256:  "some-sha",
+							Stacks:  []string{"*"},
+							URI:     "some-uri",
+							Version: "2.1.4",
+						},
+						Licenses:     []string{"MIT"},
+						RubyVersions: ">= 2.3.0",
+					},
+				},
+				VersionSourcePriorities: map[string]int{"Gemfile.lock": 5},
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when the buildpack.toml cannot be parsed", func() {
+				it.Before(func() {
+					Expect(ioutil.WriteFile(path, []byte("%%%"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := bundler.ParseBuildpackConfig(path)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse buildpack.toml")))
+				})
+			})
+		})
+	})
+}
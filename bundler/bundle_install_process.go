@@ -0,0 +1,133 @@
+package bundler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/packit/pexec"
+)
+
+//go:generate faux --interface Executable --output fakes/executable.go
+type Executable interface {
+	Execute(pexec.Execution) error
+}
+
+// bundleInstallEnabled reports whether bundle install runs for apps with a
+// Gemfile.lock. Apps that install their gems with another buildpack turn it
+// off with BP_BUNDLE_INSTALL=false.
+func bundleInstallEnabled() (bool, error) {
+	value := os.Getenv(BundleInstallEnv)
+	if value == "" {
+		return true, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: must be true or false", BundleInstallEnv, value)
+	}
+
+	return enabled, nil
+}
+
+var bundlerVersionRegex = regexp.MustCompile(`Bundler version (\S+)`)
+
+type BundleInstallProcess struct {
+	executable Executable
+}
+
+// NewBundleInstallProcess creates a BundleInstallProcess given an Executable
+// that invokes ruby. Bundler is run through ruby as it is not yet on the
+// $PATH of the build process.
+func NewBundleInstallProcess(executable Executable) BundleInstallProcess {
+	return BundleInstallProcess{
+		executable: executable,
+	}
+}
+
+func (p BundleInstallProcess) RubyVersion() (string, error) {
+	buffer := bytes.NewBuffer(nil)
+	err := p.executable.Execute(pexec.Execution{
+		Args:   []string{"-e", "puts RUBY_VERSION"},
+		Stdout: buffer,
+		Stderr: buffer,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to determine ruby version: %w\n%s", err, buffer.String())
+	}
+
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// VerifyBundlerVersion runs bundle --version from the given Bundler layer and
+// checks that it reports the expected version, returning an error that
+// includes the output of the command when it does not.
+func (p BundleInstallProcess) VerifyBundlerVersion(bundlerLayerPath, version string) error {
+	stdout := bytes.NewBuffer(nil)
+	output := bytes.NewBuffer(nil)
+	err := p.executable.Execute(pexec.Execution{
+		Args:   []string{filepath.Join(bundlerLayerPath, "bin", "bundle"), "--version"},
+		Env:    append(os.Environ(), fmt.Sprintf("GEM_PATH=%s", bundlerLayerPath)),
+		Stdout: io.MultiWriter(stdout, output),
+		Stderr: output,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute bundle --version: %w\n%s", err, output.String())
+	}
+
+	installed := strings.TrimSpace(stdout.String())
+	if match := bundlerVersionRegex.FindStringSubmatch(installed); match != nil {
+		installed = match[1]
+	}
+
+	if installed != version {
+		return fmt.Errorf("installed Bundler version %q does not match expected version %q:\n%s", installed, version, output.String())
+	}
+
+	return nil
+}
+
+// Execute runs bundle install using the Bundler in bundlerLayerPath, installing
+// gems into gemsLayerPath. The given env, such as gem server credentials, is
+// added to the environment of the process.
+func (p BundleInstallProcess) Execute(workingDir, bundlerLayerPath, gemsLayerPath string, env map[string]string) error {
+	gemPath := bundlerLayerPath
+	if existing := os.Getenv("GEM_PATH"); existing != "" {
+		gemPath = strings.Join([]string{bundlerLayerPath, existing}, string(os.PathListSeparator))
+	}
+
+	environment := append(os.Environ(),
+		fmt.Sprintf("GEM_PATH=%s", gemPath),
+		fmt.Sprintf("BUNDLE_PATH=%s", gemsLayerPath),
+	)
+
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		environment = append(environment, fmt.Sprintf("%s=%s", name, env[name]))
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	err := p.executable.Execute(pexec.Execution{
+		Args:   []string{filepath.Join(bundlerLayerPath, "bin", "bundle"), "install"},
+		Dir:    workingDir,
+		Env:    environment,
+		Stdout: buffer,
+		Stderr: buffer,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute bundle install: %w\n%s", err, buffer.String())
+	}
+
+	return nil
+}
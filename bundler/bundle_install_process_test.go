@@ -0,0 +1,162 @@
+package bundler_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/cloudfoundry/bundler-cnb/bundler/fakes"
+	"github.com/cloudfoundry/packit/pexec"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBundleInstallProcess(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		executable *fakes.Executable
+		process    bundler.BundleInstallProcess
+	)
+
+	it.Before(func() {
+		executable = &fakes.Executable{}
+
+		process = bundler.NewBundleInstallProcess(executable)
+	})
+
+	context("RubyVersion", func() {
+		it.Before(func() {
+			executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+				fmt.Fprintln(execution.Stdout, "2.7.1")
+				return nil
+			}
+		})
+
+		it("returns the version of ruby on the $PATH", func() {
+			version, err := process.RubyVersion()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("2.7.1"))
+
+			Expect(executable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"-e", "puts RUBY_VERSION"}))
+		})
+
+		context("failure cases", func() {
+			context("when ruby fails to execute", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						fmt.Fprintln(execution.Stderr, "some-ruby-error")
+						return errors.New("exit status 1")
+					}
+				})
+
+				it("returns an error", func() {
+					_, err := process.RubyVersion()
+					Expect(err).To(MatchError(ContainSubstring("failed to determine ruby version: exit status 1")))
+					Expect(err).To(MatchError(ContainSubstring("some-ruby-error")))
+				})
+			})
+		})
+	})
+
+	context("VerifyBundlerVersion", func() {
+		it.Before(func() {
+			executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+				fmt.Fprintln(execution.Stdout, "Bundler version 2.1.4")
+				return nil
+			}
+		})
+
+		it("runs bundle --version from the bundler layer", func() {
+			err := process.VerifyBundlerVersion("/layers/bundler", "2.1.4")
+			Expect(err).NotTo(HaveOccurred())
+
+			execution := executable.ExecuteCall.Receives.Execution
+			Expect(execution.Args).To(Equal([]string{"/layers/bundler/bin/bundle", "--version"}))
+			Expect(execution.Env).To(ContainElement("GEM_PATH=/layers/bundler"))
+		})
+
+		context("failure cases", func() {
+			context("when the installed version does not match", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						fmt.Fprintln(execution.Stderr, "some-warning")
+						fmt.Fprintln(execution.Stdout, "Bundler version 2.1.2")
+						return nil
+					}
+				})
+
+				it("returns an error that includes the output", func() {
+					err := process.VerifyBundlerVersion("/layers/bundler", "2.1.4")
+					Expect(err).To(MatchError("installed Bundler version \"2.1.2\" does not match expected version \"2.1.4\":\nsome-warning\nBundler version 2.1.2\n"))
+				})
+			})
+
+			context("when bundle --version fails", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						fmt.Fprintln(execution.Stderr, "cannot load such file -- bundler")
+						return errors.New("exit status 1")
+					}
+				})
+
+				it("returns an error that includes the output", func() {
+					err := process.VerifyBundlerVersion("/layers/bundler", "2.1.4")
+					Expect(err).To(MatchError(ContainSubstring("failed to execute bundle --version: exit status 1")))
+					Expect(err).To(MatchError(ContainSubstring("cannot load such file -- bundler")))
+				})
+			})
+		})
+	})
+
+	context("Execute", func() {
+		it.Before(func() {
+			Expect(os.Setenv("GEM_PATH", "/some/gem/path")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("GEM_PATH")).To(Succeed())
+		})
+
+		it("runs bundle install from the bundler layer into the gems layer", func() {
+			err := process.Execute("/working-dir", "/layers/bundler", "/layers/gems", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			execution := executable.ExecuteCall.Receives.Execution
+			Expect(execution.Args).To(Equal([]string{"/layers/bundler/bin/bundle", "install"}))
+			Expect(execution.Dir).To(Equal("/working-dir"))
+			Expect(execution.Env).To(ContainElement("GEM_PATH=/layers/bundler:/some/gem/path"))
+			Expect(execution.Env).To(ContainElement("BUNDLE_PATH=/layers/gems"))
+		})
+
+		it("adds the given env to the environment of bundle install", func() {
+			err := process.Execute("/working-dir", "/layers/bundler", "/layers/gems", map[string]string{
+				"BUNDLE_GEMS__EXAMPLE__COM": "some-user:some-password",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			execution := executable.ExecuteCall.Receives.Execution
+			Expect(execution.Env).To(ContainElement("BUNDLE_GEMS__EXAMPLE__COM=some-user:some-password"))
+		})
+
+		context("failure cases", func() {
+			context("when bundle install fails", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+						fmt.Fprintln(execution.Stdout, "Could not find gem 'some-gem'")
+						return errors.New("exit status 7")
+					}
+				})
+
+				it("returns an error that includes the output", func() {
+					err := process.Execute("/working-dir", "/layers/bundler", "/layers/gems", nil)
+					Expect(err).To(MatchError(ContainSubstring("failed to execute bundle install: exit status 7")))
+					Expect(err).To(MatchError(ContainSubstring("Could not find gem 'some-gem'")))
+				})
+			})
+		})
+	})
+}
@@ -1,15 +1,81 @@
 package bundler
 
-import "time"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ReproducibleEpoch is the timestamp used by a reproducible Clock when no
+// SOURCE_DATE_EPOCH is given. It matches the time the lifecycle normalizes
+// layer files to.
+var ReproducibleEpoch = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
 
 type Clock struct {
-	now func() time.Time
+	now          func() time.Time
+	reproducible bool
+	epoch        string
 }
 
 func NewClock(now func() time.Time) Clock {
 	return Clock{now: now}
 }
 
+// NewReproducibleClock creates a Clock whose Timestamp is fixed so that two
+// builds of the same inputs produce identical layers. The epoch is a number of
+// seconds since the Unix epoch, as in SOURCE_DATE_EPOCH, and defaults to
+// ReproducibleEpoch when empty. Now still returns the current time.
+func NewReproducibleClock(now func() time.Time, epoch string) Clock {
+	return Clock{
+		now:          now,
+		reproducible: true,
+		epoch:        epoch,
+	}
+}
+
 func (c Clock) Now() time.Time {
 	return c.now()
 }
+
+// Reproducible reports whether the Timestamp of the clock is fixed.
+func (c Clock) Reproducible() bool {
+	return c.reproducible
+}
+
+// Timestamp returns the time recorded in the layers that are built, which is
+// the fixed epoch for a reproducible clock and the current time otherwise.
+func (c Clock) Timestamp() (time.Time, error) {
+	if !c.reproducible {
+		return c.now(), nil
+	}
+
+	if c.epoch == "" {
+		return ReproducibleEpoch, nil
+	}
+
+	seconds, err := strconv.ParseInt(c.epoch, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: must be a non-negative number of seconds", c.epoch)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// setModTimes sets the access and modification times of every file and
+// directory under the given root. Symlinks are skipped since os.Chtimes would
+// change their targets instead.
+func setModTimes(root string, t time.Time) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		return os.Chtimes(path, t, t)
+	})
+}
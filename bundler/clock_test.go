@@ -24,4 +24,58 @@ func testClock(t *testing.T, context spec.G, it spec.S) {
 			Expect(clock.Now()).To(Equal(now))
 		})
 	})
+
+	context("Timestamp", func() {
+		var now time.Time
+
+		it.Before(func() {
+			now = time.Now()
+		})
+
+		it("returns the current time", func() {
+			clock := bundler.NewClock(func() time.Time {
+				return now
+			})
+
+			Expect(clock.Reproducible()).To(BeFalse())
+
+			timestamp, err := clock.Timestamp()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(timestamp).To(Equal(now))
+		})
+
+		context("when the clock is reproducible", func() {
+			it("returns the given epoch", func() {
+				clock := bundler.NewReproducibleClock(func() time.Time {
+					return now
+				}, "1600000000")
+
+				Expect(clock.Reproducible()).To(BeTrue())
+				Expect(clock.Now()).To(Equal(now))
+
+				timestamp, err := clock.Timestamp()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(timestamp).To(Equal(time.Unix(1600000000, 0).UTC()))
+			})
+
+			context("when no epoch is given", func() {
+				it("returns the reproducible epoch", func() {
+					clock := bundler.NewReproducibleClock(time.Now, "")
+
+					timestamp, err := clock.Timestamp()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(timestamp).To(Equal(bundler.ReproducibleEpoch))
+				})
+			})
+
+			context("when the epoch is invalid", func() {
+				it("returns an error", func() {
+					clock := bundler.NewReproducibleClock(time.Now, "-1")
+
+					_, err := clock.Timestamp()
+					Expect(err).To(MatchError(`invalid SOURCE_DATE_EPOCH "-1": must be a non-negative number of seconds`))
+				})
+			})
+		})
+	})
 }
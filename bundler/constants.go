@@ -1,8 +1,22 @@
 package bundler
 
 const (
-	Bundler            = "bundler"
-	BuildpackYMLSource = "buildpack.yml"
+	Bundler               = "bundler"
+	Gems                  = "gems"
+	GemCredentials        = "gem-credentials"
+	BuildpackYMLSource    = "buildpack.yml"
+	GemfileLockSource     = "Gemfile.lock"
+	EnvironmentSource     = "BP_BUNDLER_VERSION"
+	BundleInstallEnv      = "BP_BUNDLE_INSTALL"
+	DependencyAttemptsEnv = "BP_DEPENDENCY_ATTEMPTS"
+	DependencyTimeoutEnv  = "BP_DEPENDENCY_TIMEOUT"
+	MRI                   = "mri"
 
-	DepKey = "dependency-sha"
+	DepKey              = "dependency-sha"
+	GemfileLockKey      = "gemfile-lock-sha"
+	BundlerVersionKey   = "bundler-version"
+	RubyVersionKey      = "ruby-version"
+	StackKey            = "stack"
+	BuildpackVersionKey = "buildpack-version"
+	BuiltAtKey          = "built_at"
 )
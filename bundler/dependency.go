@@ -0,0 +1,32 @@
+package bundler
+
+import (
+	"github.com/cloudfoundry/packit/postal"
+)
+
+// Dependency is a representation of a dependency in buildpack.toml. It
+// extends postal.Dependency with the fields that postal does not parse.
+type Dependency struct {
+	postal.Dependency
+
+	// Licenses is a list of SPDX license expressions that apply to the
+	// dependency.
+	Licenses []string `toml:"licenses"`
+
+	// RubyVersions is the semver range of Ruby versions the dependency is
+	// compatible with. An empty range is compatible with every Ruby.
+	RubyVersions string `toml:"ruby_versions"`
+}
+
+// parseDependency finds the buildpack.toml entry matching the dependency
+// resolved by postal so that its additional fields can be read.
+func parseDependency(config BuildpackConfig, dependency postal.Dependency) Dependency {
+	for _, d := range config.Dependencies {
+		if d.ID == dependency.ID && d.Version == dependency.Version && d.SHA256 == dependency.SHA256 {
+			d.Dependency = dependency
+			return d
+		}
+	}
+
+	return Dependency{Dependency: dependency}
+}
@@ -0,0 +1,41 @@
+package bundler
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const DependencyMappingBindingType = "dependency-mapping"
+
+// mapDependencyURI finds a dependency-mapping binding entry keyed by the
+// given checksum and returns the uri it maps to along with the name of the
+// binding that provided it.
+func mapDependencyURI(bindings []Binding, sha256, cnbPath string) (string, string, bool, error) {
+	for _, binding := range bindings {
+		value, ok := binding.Entries[sha256]
+		if !ok || value == "" {
+			continue
+		}
+
+		if strings.Contains(value, "://") && !strings.HasPrefix(value, "file://") {
+			return value, binding.Name, true, nil
+		}
+
+		path := strings.TrimPrefix(value, "file://")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(binding.Path, path)
+		}
+
+		// Transports resolve file:// uris relative to the buildpack root, so
+		// local files are expressed relative to it.
+		rel, err := filepath.Rel(cnbPath, path)
+		if err != nil {
+			return "", "", false, fmt.Errorf("failed to map dependency to %s: %w", path, err)
+		}
+
+		return fmt.Sprintf("file://%s", rel), binding.Name, true, nil
+	}
+
+	return "", "", false, nil
+}
@@ -0,0 +1,75 @@
+package bundler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/packit/postal"
+)
+
+const (
+	DeprecationWarn  = "warn"
+	DeprecationFail  = "fail"
+	DeprecationGrace = "grace"
+)
+
+// DeprecationPolicy decides whether a deprecated dependency may still be
+// selected.
+type DeprecationPolicy struct {
+	policy string
+	clock  Clock
+}
+
+// NewDeprecationPolicy creates a DeprecationPolicy from one of warn, fail or
+// grace:<days>. With warn, which is also used when the policy is empty,
+// deprecated dependencies are always allowed. With fail they are rejected
+// from their deprecation date on, and with grace:<days> only once the given
+// number of days have passed since that date.
+func NewDeprecationPolicy(policy string, clock Clock) DeprecationPolicy {
+	return DeprecationPolicy{
+		policy: policy,
+		clock:  clock,
+	}
+}
+
+// Check returns an error when the policy does not allow the dependency to be
+// selected at the current time of the clock.
+func (p DeprecationPolicy) Check(dependency postal.Dependency) error {
+	grace, enforced, err := p.parse()
+	if err != nil {
+		return err
+	}
+
+	if !enforced || (dependency.DeprecationDate == time.Time{}) {
+		return nil
+	}
+
+	deadline := dependency.DeprecationDate.Add(grace)
+	if p.clock.Now().Before(deadline) {
+		return nil
+	}
+
+	return fmt.Errorf("%s %s was deprecated on %s and is no longer allowed after %s by deprecation policy %q", dependency.Name, dependency.Version, dependency.DeprecationDate.Format("2006-01-02"), deadline.Format("2006-01-02"), p.policy)
+}
+
+// parse returns the grace period of the policy and whether the policy is
+// enforced at all.
+func (p DeprecationPolicy) parse() (time.Duration, bool, error) {
+	policy := strings.ToLower(strings.TrimSpace(p.policy))
+
+	switch {
+	case policy == "" || policy == DeprecationWarn:
+		return 0, false, nil
+	case policy == DeprecationFail:
+		return 0, true, nil
+	case strings.HasPrefix(policy, DeprecationGrace+":"):
+		days, err := strconv.Atoi(strings.TrimPrefix(policy, DeprecationGrace+":"))
+		if err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour, true, nil
+		}
+	}
+
+	return 0, false, fmt.Errorf("invalid deprecation policy %q: must be %q, %q or %q", p.policy, DeprecationWarn, DeprecationFail, "grace:<days>")
+}
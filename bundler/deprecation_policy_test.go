@@ -0,0 +1,93 @@
+package bundler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/cloudfoundry/packit/postal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDeprecationPolicy(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		now        time.Time
+		clock      bundler.Clock
+		dependency postal.Dependency
+	)
+
+	it.Before(func() {
+		clock = bundler.NewClock(func() time.Time {
+			return now
+		})
+
+		dependency = postal.Dependency{
+			Name:            "Bundler",
+			Version:         "1.17.3",
+			DeprecationDate: time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC),
+		}
+	})
+
+	context("Check", func() {
+		context("when the policy is warn", func() {
+			it("allows deprecated dependencies", func() {
+				now = dependency.DeprecationDate.Add(365 * 24 * time.Hour)
+
+				Expect(bundler.NewDeprecationPolicy("warn", clock).Check(dependency)).To(Succeed())
+				Expect(bundler.NewDeprecationPolicy("", clock).Check(dependency)).To(Succeed())
+			})
+		})
+
+		context("when the policy is fail", func() {
+			it("allows the dependency until its deprecation date", func() {
+				now = dependency.DeprecationDate.Add(-time.Second)
+
+				Expect(bundler.NewDeprecationPolicy("fail", clock).Check(dependency)).To(Succeed())
+			})
+
+			it("rejects the dependency from its deprecation date on", func() {
+				now = dependency.DeprecationDate
+
+				err := bundler.NewDeprecationPolicy("fail", clock).Check(dependency)
+				Expect(err).To(MatchError(`Bundler 1.17.3 was deprecated on 2021-04-01 and is no longer allowed after 2021-04-01 by deprecation policy "fail"`))
+			})
+
+			it("allows dependencies without a deprecation date", func() {
+				now = dependency.DeprecationDate
+
+				dependency.DeprecationDate = time.Time{}
+				Expect(bundler.NewDeprecationPolicy("fail", clock).Check(dependency)).To(Succeed())
+			})
+		})
+
+		context("when the policy has a grace period", func() {
+			it("allows the dependency during the grace period", func() {
+				now = dependency.DeprecationDate.Add(29 * 24 * time.Hour)
+
+				Expect(bundler.NewDeprecationPolicy("grace:30", clock).Check(dependency)).To(Succeed())
+			})
+
+			it("rejects the dependency after the grace period", func() {
+				now = dependency.DeprecationDate.Add(30 * 24 * time.Hour)
+
+				err := bundler.NewDeprecationPolicy("grace:30", clock).Check(dependency)
+				Expect(err).To(MatchError(`Bundler 1.17.3 was deprecated on 2021-04-01 and is no longer allowed after 2021-05-01 by deprecation policy "grace:30"`))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the policy is invalid", func() {
+				it("returns an error", func() {
+					for _, policy := range []string{"ignore", "grace:", "grace:-1", "grace:soon"} {
+						err := bundler.NewDeprecationPolicy(policy, clock).Check(dependency)
+						Expect(err).To(MatchError(ContainSubstring("invalid deprecation policy")), policy)
+					}
+				})
+			})
+		})
+	})
+}
@@ -1,7 +1,10 @@
 package bundler
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cloudfoundry/packit"
 )
@@ -12,12 +15,32 @@ type VersionParser interface {
 }
 
 type BuildPlanMetadata struct {
-	VersionSource string `toml:"version-source"`
+	VersionSource string `toml:"version-source,omitempty"`
+	Launch        bool   `toml:"launch,omitempty"`
+	Build         bool   `toml:"build,omitempty"`
 }
 
-func Detect(buildpackYMLParser VersionParser) packit.DetectFunc {
+func Detect(buildpackYMLParser, gemfileLockParser VersionParser, logger LogEmitter) packit.DetectFunc {
 	return func(context packit.DetectContext) (packit.DetectResult, error) {
+		ok, err := hasGemfile(context.WorkingDir)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		// BP_BUNDLER_VERSION is often set builder-wide, so it only makes apps
+		// that use bundler in the first place require it. Build still applies
+		// the version when another buildpack requires bundler.
 		var requirements []packit.BuildPlanRequirement
+		if version := os.Getenv(EnvironmentSource); version != "" && ok {
+			requirements = append(requirements, packit.BuildPlanRequirement{
+				Name:    Bundler,
+				Version: version,
+				Metadata: BuildPlanMetadata{
+					VersionSource: EnvironmentSource,
+				},
+			})
+		}
+
 		version, err := buildpackYMLParser.ParseVersion(filepath.Join(context.WorkingDir, BuildpackYMLSource))
 		if err != nil {
 			return packit.DetectResult{}, err
@@ -33,6 +56,79 @@ func Detect(buildpackYMLParser VersionParser) packit.DetectFunc {
 			})
 		}
 
+		version, err = gemfileLockParser.ParseVersion(filepath.Join(context.WorkingDir, GemfileLockSource))
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		if version != "" {
+			// A Gemfile.lock is only guaranteed to be compatible with the major
+			// version of Bundler that produced it, so only that is pinned.
+			majorVersion := strings.Split(version, ".")[0]
+
+			requirements = append(requirements, packit.BuildPlanRequirement{
+				Name:    Bundler,
+				Version: fmt.Sprintf("%s.*.*", majorVersion),
+				Metadata: BuildPlanMetadata{
+					VersionSource: GemfileLockSource,
+				},
+			})
+		}
+
+		// An app with a Gemfile runs through bundler, so bundler has to be
+		// available at launch.
+		if ok {
+			for i := range requirements {
+				metadata := requirements[i].Metadata.(BuildPlanMetadata)
+				metadata.Launch = true
+				requirements[i].Metadata = metadata
+			}
+		}
+
+		// Without a Gemfile or an explicit version request the app has no use
+		// for bundler itself, so it is only provided. The lifecycle fails this
+		// group unless some other buildpack requires bundler.
+		if ok && len(requirements) == 0 {
+			requirements = append(requirements, packit.BuildPlanRequirement{
+				Name: Bundler,
+				Metadata: BuildPlanMetadata{
+					Launch: true,
+				},
+			})
+		}
+
+		// The lifecycle runs detect for every buildpack it considers, so only
+		// structured output, which is read by tools, records the requirements.
+		if logger.structured() {
+			logger.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
+			if len(requirements) > 0 {
+				logger.Requirements(requirements)
+			}
+		}
+
+		// bundle install runs through ruby, so an app with a Gemfile.lock needs
+		// it during the build unless the gems are installed elsewhere.
+		install, err := bundleInstallEnabled()
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		if ok && install {
+			_, err = os.Stat(filepath.Join(context.WorkingDir, GemfileLockSource))
+			if err != nil && !os.IsNotExist(err) {
+				return packit.DetectResult{}, fmt.Errorf("failed to stat %s: %w", GemfileLockSource, err)
+			}
+
+			if err == nil {
+				requirements = append(requirements, packit.BuildPlanRequirement{
+					Name: MRI,
+					Metadata: BuildPlanMetadata{
+						Build: true,
+					},
+				})
+			}
+		}
+
 		return packit.DetectResult{
 			Plan: packit.BuildPlan{
 				Provides: []packit.BuildPlanProvision{
@@ -43,3 +139,23 @@ func Detect(buildpackYMLParser VersionParser) packit.DetectFunc {
 		}, nil
 	}
 }
+
+func hasGemfile(workingDir string) (bool, error) {
+	for _, name := range []string{"Gemfile", "gems.rb"} {
+		_, err := os.Stat(filepath.Join(workingDir, name))
+		if err == nil {
+			return true, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to stat %s: %w", name, err)
+		}
+	}
+
+	gemspecs, err := filepath.Glob(filepath.Join(workingDir, "*.gemspec"))
+	if err != nil {
+		return false, fmt.Errorf("failed to glob gemspec files: %w", err)
+	}
+
+	return len(gemspecs) > 0, nil
+}
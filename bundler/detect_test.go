@@ -1,7 +1,11 @@
 package bundler_test
 
 import (
+	"bytes"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cloudfoundry/bundler-cnb/bundler"
@@ -17,13 +21,17 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		Expect = NewWithT(t).Expect
 
 		buildpackYMLParser *fakes.VersionParser
+		gemfileLockParser  *fakes.VersionParser
+		buffer             *bytes.Buffer
 		detect             packit.DetectFunc
 	)
 
 	it.Before(func() {
 		buildpackYMLParser = &fakes.VersionParser{}
+		gemfileLockParser = &fakes.VersionParser{}
 
-		detect = bundler.Detect(buildpackYMLParser)
+		buffer = bytes.NewBuffer(nil)
+		detect = bundler.Detect(buildpackYMLParser, gemfileLockParser, bundler.NewLogEmitter(buffer))
 	})
 
 	it("returns a plan that provides bundler", func() {
@@ -38,6 +46,182 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		}))
 	})
 
+	context("when the source code contains a Gemfile", func() {
+		var workingDir string
+
+		it.Before(func() {
+			var err error
+			workingDir, err = ioutil.TempDir("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ioutil.WriteFile(filepath.Join(workingDir, "Gemfile"), nil, 0644)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+		})
+
+		it("returns a plan that provides and requires bundler", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: bundler.Bundler},
+				},
+				Requires: []packit.BuildPlanRequirement{
+					{
+						Name:     bundler.Bundler,
+						Metadata: bundler.BuildPlanMetadata{Launch: true},
+					},
+				},
+			}))
+		})
+
+		context("when a version of bundler is also requested", func() {
+			it.Before(func() {
+				gemfileLockParser.ParseVersionCall.Returns.Version = "2.1.4"
+			})
+
+			it("requires that version of bundler at launch", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name:    bundler.Bundler,
+						Version: "2.*.*",
+						Metadata: bundler.BuildPlanMetadata{
+							VersionSource: "Gemfile.lock",
+							Launch:        true,
+						},
+					},
+				}))
+			})
+		})
+
+		context("when the app has a Gemfile.lock", func() {
+			it.Before(func() {
+				Expect(ioutil.WriteFile(filepath.Join(workingDir, "Gemfile.lock"), nil, 0644)).To(Succeed())
+			})
+
+			it("also requires mri to run bundle install", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name:     bundler.Bundler,
+						Metadata: bundler.BuildPlanMetadata{Launch: true},
+					},
+					{
+						Name:     "mri",
+						Metadata: bundler.BuildPlanMetadata{Build: true},
+					},
+				}))
+			})
+
+			context("when BP_BUNDLE_INSTALL is false", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_BUNDLE_INSTALL", "false")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_BUNDLE_INSTALL")).To(Succeed())
+				})
+
+				it("does not require mri", func() {
+					result, err := detect(packit.DetectContext{
+						WorkingDir: workingDir,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+						{
+							Name:     bundler.Bundler,
+							Metadata: bundler.BuildPlanMetadata{Launch: true},
+						},
+					}))
+				})
+			})
+
+			context("when BP_BUNDLE_INSTALL is invalid", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_BUNDLE_INSTALL", "sometimes")).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Unsetenv("BP_BUNDLE_INSTALL")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := detect(packit.DetectContext{
+						WorkingDir: workingDir,
+					})
+					Expect(err).To(MatchError(`invalid BP_BUNDLE_INSTALL "sometimes": must be true or false`))
+				})
+			})
+		})
+
+		context("when the Gemfile is named gems.rb", func() {
+			it.Before(func() {
+				Expect(os.Rename(filepath.Join(workingDir, "Gemfile"), filepath.Join(workingDir, "gems.rb"))).To(Succeed())
+			})
+
+			it("returns a plan that provides and requires bundler", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name:     bundler.Bundler,
+						Metadata: bundler.BuildPlanMetadata{Launch: true},
+					},
+				}))
+			})
+		})
+
+		context("when there is only a gemspec", func() {
+			it.Before(func() {
+				Expect(os.Rename(filepath.Join(workingDir, "Gemfile"), filepath.Join(workingDir, "some-gem.gemspec"))).To(Succeed())
+			})
+
+			it("returns a plan that provides and requires bundler", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name:     bundler.Bundler,
+						Metadata: bundler.BuildPlanMetadata{Launch: true},
+					},
+				}))
+			})
+		})
+
+		context("when the Gemfile cannot be stat'd", func() {
+			it.Before(func() {
+				Expect(os.Chmod(workingDir, 0000)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Chmod(workingDir, os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to stat Gemfile")))
+				Expect(err).To(MatchError(ContainSubstring("permission denied")))
+			})
+		})
+	})
+
 	context("when the source code contains a buildpack.yml file", func() {
 		it.Before(func() {
 			buildpackYMLParser.ParseVersionCall.Returns.Version = "4.5.6"
@@ -67,6 +251,182 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when the source code contains a Gemfile.lock file", func() {
+		it.Before(func() {
+			gemfileLockParser.ParseVersionCall.Returns.Version = "1.17.3"
+		})
+
+		it("returns a plan that provides and requires the major version of bundler", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: "/working-dir",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: bundler.Bundler},
+				},
+				Requires: []packit.BuildPlanRequirement{
+					{
+						Name:    bundler.Bundler,
+						Version: "1.*.*",
+						Metadata: bundler.BuildPlanMetadata{
+							VersionSource: "Gemfile.lock",
+						},
+					},
+				},
+			}))
+
+			Expect(gemfileLockParser.ParseVersionCall.Receives.Path).To(Equal("/working-dir/Gemfile.lock"))
+		})
+
+		context("when the source code also contains a buildpack.yml file", func() {
+			it.Before(func() {
+				buildpackYMLParser.ParseVersionCall.Returns.Version = "4.5.6"
+			})
+
+			it("returns a plan that requires both versions of bundler", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name:    bundler.Bundler,
+						Version: "4.5.6",
+						Metadata: bundler.BuildPlanMetadata{
+							VersionSource: "buildpack.yml",
+						},
+					},
+					{
+						Name:    bundler.Bundler,
+						Version: "1.*.*",
+						Metadata: bundler.BuildPlanMetadata{
+							VersionSource: "Gemfile.lock",
+						},
+					},
+				}))
+			})
+
+			it("prints nothing", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buffer.String()).To(BeEmpty())
+			})
+
+			context("when the log format is JSON", func() {
+				it.Before(func() {
+					detect = bundler.Detect(buildpackYMLParser, gemfileLockParser, bundler.NewJSONLogEmitter(buffer, "detect"))
+				})
+
+				it("logs the requested versions", func() {
+					_, err := detect(packit.DetectContext{
+						WorkingDir: "/working-dir",
+						BuildpackInfo: packit.BuildpackInfo{
+							Name:    "Some Buildpack",
+							Version: "some-version",
+						},
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(buffer.String()).To(Equal(`{"phase":"detect","event":"title","message":"Some Buildpack some-version"}
+{"phase":"detect","event":"requirements","requirements":[{"source":"buildpack.yml","version":"4.5.6"},{"source":"Gemfile.lock","version":"1.*.*"}]}
+`))
+				})
+			})
+		})
+	})
+
+	context("when BP_BUNDLER_VERSION is set", func() {
+		var workingDir string
+
+		it.Before(func() {
+			Expect(os.Setenv("BP_BUNDLER_VERSION", "7.8.9")).To(Succeed())
+
+			var err error
+			workingDir, err = ioutil.TempDir("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ioutil.WriteFile(filepath.Join(workingDir, "Gemfile"), nil, 0644)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_BUNDLER_VERSION")).To(Succeed())
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+		})
+
+		it("returns a plan that provides and requires that version of bundler", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: bundler.Bundler},
+				},
+				Requires: []packit.BuildPlanRequirement{
+					{
+						Name:    bundler.Bundler,
+						Version: "7.8.9",
+						Metadata: bundler.BuildPlanMetadata{
+							VersionSource: "BP_BUNDLER_VERSION",
+							Launch:        true,
+						},
+					},
+				},
+			}))
+		})
+
+		context("when the source code also contains a buildpack.yml file", func() {
+			it.Before(func() {
+				buildpackYMLParser.ParseVersionCall.Returns.Version = "4.5.6"
+			})
+
+			it("returns a plan that requires both versions of bundler", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan.Requires).To(Equal([]packit.BuildPlanRequirement{
+					{
+						Name:    bundler.Bundler,
+						Version: "7.8.9",
+						Metadata: bundler.BuildPlanMetadata{
+							VersionSource: "BP_BUNDLER_VERSION",
+							Launch:        true,
+						},
+					},
+					{
+						Name:    bundler.Bundler,
+						Version: "4.5.6",
+						Metadata: bundler.BuildPlanMetadata{
+							VersionSource: "buildpack.yml",
+							Launch:        true,
+						},
+					},
+				}))
+			})
+		})
+
+		context("when the app has no Gemfile, gems.rb or gemspec", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "Gemfile"))).To(Succeed())
+			})
+
+			it("only provides bundler", func() {
+				result, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Plan).To(Equal(packit.BuildPlan{
+					Provides: []packit.BuildPlanProvision{
+						{Name: bundler.Bundler},
+					},
+				}))
+			})
+		})
+	})
+
 	context("failure cases", func() {
 		context("when the buildpack.yml parser fails", func() {
 			it.Before(func() {
@@ -80,5 +440,18 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 				Expect(err).To(MatchError("failed to parse buildpack.yml"))
 			})
 		})
+
+		context("when the Gemfile.lock parser fails", func() {
+			it.Before(func() {
+				gemfileLockParser.ParseVersionCall.Returns.Err = errors.New("failed to parse Gemfile.lock")
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: "/working-dir",
+				})
+				Expect(err).To(MatchError("failed to parse Gemfile.lock"))
+			})
+		})
 	})
 }
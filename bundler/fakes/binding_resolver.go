@@ -0,0 +1,33 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+)
+
+type BindingResolver struct {
+	ResolveCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			Typ string
+		}
+		Returns struct {
+			BindingSlice []bundler.Binding
+			Error        error
+		}
+		Stub func(string) ([]bundler.Binding, error)
+	}
+}
+
+func (f *BindingResolver) Resolve(param1 string) ([]bundler.Binding, error) {
+	f.ResolveCall.Lock()
+	defer f.ResolveCall.Unlock()
+	f.ResolveCall.CallCount++
+	f.ResolveCall.Receives.Typ = param1
+	if f.ResolveCall.Stub != nil {
+		return f.ResolveCall.Stub(param1)
+	}
+	return f.ResolveCall.Returns.BindingSlice, f.ResolveCall.Returns.Error
+}
@@ -3,8 +3,8 @@ package fakes
 import (
 	"sync"
 
+	"github.com/cloudfoundry/bundler-cnb/bundler"
 	"github.com/cloudfoundry/packit"
-	"github.com/cloudfoundry/packit/postal"
 )
 
 type BuildPlanRefinery struct {
@@ -12,22 +12,25 @@ type BuildPlanRefinery struct {
 		sync.Mutex
 		CallCount int
 		Receives  struct {
-			Dependency postal.Dependency
+			Dependency bundler.Dependency
+			WorkingDir string
 		}
 		Returns struct {
 			BuildpackPlan packit.BuildpackPlan
+			Error         error
 		}
-		Stub func(postal.Dependency) packit.BuildpackPlan
+		Stub func(bundler.Dependency, string) (packit.BuildpackPlan, error)
 	}
 }
 
-func (f *BuildPlanRefinery) BillOfMaterial(param1 postal.Dependency) packit.BuildpackPlan {
+func (f *BuildPlanRefinery) BillOfMaterial(param1 bundler.Dependency, param2 string) (packit.BuildpackPlan, error) {
 	f.BillOfMaterialCall.Lock()
 	defer f.BillOfMaterialCall.Unlock()
 	f.BillOfMaterialCall.CallCount++
 	f.BillOfMaterialCall.Receives.Dependency = param1
+	f.BillOfMaterialCall.Receives.WorkingDir = param2
 	if f.BillOfMaterialCall.Stub != nil {
-		return f.BillOfMaterialCall.Stub(param1)
+		return f.BillOfMaterialCall.Stub(param1, param2)
 	}
-	return f.BillOfMaterialCall.Returns.BuildpackPlan
+	return f.BillOfMaterialCall.Returns.BuildpackPlan, f.BillOfMaterialCall.Returns.Error
 }
@@ -3,6 +3,7 @@ package fakes
 import (
 	"sync"
 
+	"github.com/cloudfoundry/bundler-cnb/bundler"
 	"github.com/cloudfoundry/packit"
 )
 
@@ -11,22 +12,25 @@ type EntryResolver struct {
 		sync.Mutex
 		CallCount int
 		Receives  struct {
+			Config                  bundler.BuildpackConfig
 			BuildpackPlanEntrySlice []packit.BuildpackPlanEntry
 		}
 		Returns struct {
 			BuildpackPlanEntry packit.BuildpackPlanEntry
+			Error              error
 		}
-		Stub func([]packit.BuildpackPlanEntry) packit.BuildpackPlanEntry
+		Stub func(bundler.BuildpackConfig, []packit.BuildpackPlanEntry) (packit.BuildpackPlanEntry, error)
 	}
 }
 
-func (f *EntryResolver) Resolve(param1 []packit.BuildpackPlanEntry) packit.BuildpackPlanEntry {
+func (f *EntryResolver) Resolve(param1 bundler.BuildpackConfig, param2 []packit.BuildpackPlanEntry) (packit.BuildpackPlanEntry, error) {
 	f.ResolveCall.Lock()
 	defer f.ResolveCall.Unlock()
 	f.ResolveCall.CallCount++
-	f.ResolveCall.Receives.BuildpackPlanEntrySlice = param1
+	f.ResolveCall.Receives.Config = param1
+	f.ResolveCall.Receives.BuildpackPlanEntrySlice = param2
 	if f.ResolveCall.Stub != nil {
-		return f.ResolveCall.Stub(param1)
+		return f.ResolveCall.Stub(param1, param2)
 	}
-	return f.ResolveCall.Returns.BuildpackPlanEntry
+	return f.ResolveCall.Returns.BuildpackPlanEntry, f.ResolveCall.Returns.Error
 }
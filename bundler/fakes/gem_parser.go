@@ -0,0 +1,33 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+)
+
+type GemParser struct {
+	ParseGemsCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			Path string
+		}
+		Returns struct {
+			GemSlice []bundler.Gem
+			Error    error
+		}
+		Stub func(string) ([]bundler.Gem, error)
+	}
+}
+
+func (f *GemParser) ParseGems(param1 string) ([]bundler.Gem, error) {
+	f.ParseGemsCall.Lock()
+	defer f.ParseGemsCall.Unlock()
+	f.ParseGemsCall.CallCount++
+	f.ParseGemsCall.Receives.Path = param1
+	if f.ParseGemsCall.Stub != nil {
+		return f.ParseGemsCall.Stub(param1)
+	}
+	return f.ParseGemsCall.Returns.GemSlice, f.ParseGemsCall.Returns.Error
+}
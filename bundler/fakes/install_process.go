@@ -0,0 +1,75 @@
+package fakes
+
+import "sync"
+
+type InstallProcess struct {
+	ExecuteCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			WorkingDir       string
+			BundlerLayerPath string
+			GemsLayerPath    string
+			Env              map[string]string
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(string, string, string, map[string]string) error
+	}
+	RubyVersionCall struct {
+		sync.Mutex
+		CallCount int
+		Returns   struct {
+			String string
+			Error  error
+		}
+		Stub func() (string, error)
+	}
+	VerifyBundlerVersionCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			BundlerLayerPath string
+			Version          string
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(string, string) error
+	}
+}
+
+func (f *InstallProcess) Execute(param1 string, param2 string, param3 string, param4 map[string]string) error {
+	f.ExecuteCall.Lock()
+	defer f.ExecuteCall.Unlock()
+	f.ExecuteCall.CallCount++
+	f.ExecuteCall.Receives.WorkingDir = param1
+	f.ExecuteCall.Receives.BundlerLayerPath = param2
+	f.ExecuteCall.Receives.GemsLayerPath = param3
+	f.ExecuteCall.Receives.Env = param4
+	if f.ExecuteCall.Stub != nil {
+		return f.ExecuteCall.Stub(param1, param2, param3, param4)
+	}
+	return f.ExecuteCall.Returns.Error
+}
+func (f *InstallProcess) RubyVersion() (string, error) {
+	f.RubyVersionCall.Lock()
+	defer f.RubyVersionCall.Unlock()
+	f.RubyVersionCall.CallCount++
+	if f.RubyVersionCall.Stub != nil {
+		return f.RubyVersionCall.Stub()
+	}
+	return f.RubyVersionCall.Returns.String, f.RubyVersionCall.Returns.Error
+}
+func (f *InstallProcess) VerifyBundlerVersion(param1 string, param2 string) error {
+	f.VerifyBundlerVersionCall.Lock()
+	defer f.VerifyBundlerVersionCall.Unlock()
+	f.VerifyBundlerVersionCall.CallCount++
+	f.VerifyBundlerVersionCall.Receives.BundlerLayerPath = param1
+	f.VerifyBundlerVersionCall.Receives.Version = param2
+	if f.VerifyBundlerVersionCall.Stub != nil {
+		return f.VerifyBundlerVersionCall.Stub(param1, param2)
+	}
+	return f.VerifyBundlerVersionCall.Returns.Error
+}
@@ -0,0 +1,37 @@
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+)
+
+type SBOMGenerator struct {
+	GenerateCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			Dependency bundler.Dependency
+			LayerPath  string
+			Now        time.Time
+		}
+		Returns struct {
+			Error error
+		}
+		Stub func(bundler.Dependency, string, time.Time) error
+	}
+}
+
+func (f *SBOMGenerator) Generate(param1 bundler.Dependency, param2 string, param3 time.Time) error {
+	f.GenerateCall.Lock()
+	defer f.GenerateCall.Unlock()
+	f.GenerateCall.CallCount++
+	f.GenerateCall.Receives.Dependency = param1
+	f.GenerateCall.Receives.LayerPath = param2
+	f.GenerateCall.Receives.Now = param3
+	if f.GenerateCall.Stub != nil {
+		return f.GenerateCall.Stub(param1, param2, param3)
+	}
+	return f.GenerateCall.Returns.Error
+}
@@ -0,0 +1,115 @@
+package bundler
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	BundlerBindingType        = "bundler"
+	GemCredentialsBindingType = "gem-credentials"
+)
+
+// GemCredential is the credential bundler uses to authenticate against a
+// single gem server.
+type GemCredential struct {
+	Binding string
+	Host    string
+	Value   string
+}
+
+// EnvName returns the variable bundler reads the credential from, following
+// bundler's own conversion of configuration keys: gems.example.com becomes
+// BUNDLE_GEMS__EXAMPLE__COM.
+func (c GemCredential) EnvName() string {
+	key := strings.ToUpper(c.Host)
+	key = strings.ReplaceAll(key, "-", "___")
+	key = strings.ReplaceAll(key, ".", "__")
+
+	return fmt.Sprintf("BUNDLE_%s", key)
+}
+
+// secrets returns the values that must not show up in build output. The
+// password of username:password credentials is included on its own as it is
+// commonly echoed back without the username.
+func (c GemCredential) secrets() []string {
+	secrets := []string{c.Value}
+	if parts := strings.SplitN(c.Value, ":", 2); len(parts) == 2 && parts[1] != "" {
+		secrets = append(secrets, parts[1])
+	}
+
+	return secrets
+}
+
+// parseGemCredentials reads the gem server credentials out of the given
+// bindings. A binding either provides a host entry along with a username and
+// password (or a token), or maps each gem server host directly to its
+// credentials.
+func parseGemCredentials(bindings []Binding) ([]GemCredential, error) {
+	var credentials []GemCredential
+	for _, binding := range bindings {
+		if host, ok := binding.Entries["host"]; ok {
+			value := binding.Entries["token"]
+			if value == "" {
+				username, password := binding.Entries["username"], binding.Entries["password"]
+				if username == "" || password == "" {
+					return nil, fmt.Errorf("failed to read gem credentials from binding %s: requires a token or a username and password for host %s", binding.Name, host)
+				}
+
+				value = fmt.Sprintf("%s:%s", username, password)
+			}
+
+			credential, err := newGemCredential(binding.Name, host, value)
+			if err != nil {
+				return nil, err
+			}
+
+			credentials = append(credentials, credential)
+			continue
+		}
+
+		var hosts []string
+		for host := range binding.Entries {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+
+		for _, host := range hosts {
+			credential, err := newGemCredential(binding.Name, host, binding.Entries[host])
+			if err != nil {
+				return nil, err
+			}
+
+			credentials = append(credentials, credential)
+		}
+	}
+
+	return credentials, nil
+}
+
+func newGemCredential(binding, host, value string) (GemCredential, error) {
+	// Gem sources are often written down as full urls, but bundler only looks
+	// credentials up by hostname.
+	if strings.Contains(host, "://") {
+		source, err := url.Parse(host)
+		if err != nil {
+			// The parse error is not wrapped as it would repeat the url along
+			// with any userinfo embedded in it.
+			return GemCredential{}, fmt.Errorf("failed to read gem credentials from binding %s: invalid gem source url", binding)
+		}
+
+		host = source.Hostname()
+	}
+
+	if host == "" || value == "" {
+		return GemCredential{}, fmt.Errorf("failed to read gem credentials from binding %s: host and credentials must not be empty", binding)
+	}
+
+	return GemCredential{
+		Binding: binding,
+		Host:    host,
+		Value:   value,
+	}, nil
+}
@@ -0,0 +1,111 @@
+package bundler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/packit/cargo"
+	"github.com/cloudfoundry/packit/pexec"
+	"github.com/cloudfoundry/packit/postal"
+)
+
+// GemDependencyManager installs dependencies that are distributed as a .gem
+// file using gem install, and hands every other dependency to the wrapped
+// DependencyManager.
+type GemDependencyManager struct {
+	dependencies DependencyManager
+	transport    Transport
+	gem          Executable
+}
+
+// NewGemDependencyManager creates a GemDependencyManager given the
+// DependencyManager for pre-built dependencies, the Transport used to fetch
+// .gem files and an Executable that invokes gem.
+func NewGemDependencyManager(dependencies DependencyManager, transport Transport, gem Executable) GemDependencyManager {
+	return GemDependencyManager{
+		dependencies: dependencies,
+		transport:    transport,
+		gem:          gem,
+	}
+}
+
+func (m GemDependencyManager) Resolve(path, id, version, stack string) (postal.Dependency, error) {
+	return m.dependencies.Resolve(path, id, version, stack)
+}
+
+func (m GemDependencyManager) Install(dependency postal.Dependency, cnbPath, layerPath string) error {
+	if !isGem(dependency.URI) {
+		return m.dependencies.Install(dependency, cnbPath, layerPath)
+	}
+
+	bundle, err := m.transport.Drop(cnbPath, dependency.URI)
+	if err != nil {
+		return fmt.Errorf("failed to fetch dependency: %w", err)
+	}
+	defer bundle.Close()
+
+	dir, err := ioutil.TempDir("", "gem")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// gem install --local looks the gem up by its file name, which has to keep
+	// the .gem extension.
+	gemFile := filepath.Join(dir, fmt.Sprintf("%s-%s.gem", dependency.ID, dependency.Version))
+	file, err := os.Create(gemFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, cargo.NewValidatedReader(bundle, dependency.SHA256))
+	if err != nil {
+		if err == cargo.ChecksumValidationError {
+			return fmt.Errorf("checksum does not match: expected %s", dependency.SHA256)
+		}
+
+		return fmt.Errorf("failed to fetch dependency: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	err = m.gem.Execute(pexec.Execution{
+		Args: []string{
+			"install", "--local",
+			"--install-dir", layerPath,
+			"--bindir", filepath.Join(layerPath, "bin"),
+			"--no-document",
+			gemFile,
+		},
+		Dir:    dir,
+		Stdout: buffer,
+		Stderr: buffer,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute gem install: %w\n%s", err, buffer.String())
+	}
+
+	return nil
+}
+
+// isGem reports whether the uri points at a .gem file, ignoring any query or
+// fragment. The uri is not parsed as file:// uris relative to the buildpack
+// would put the file name in the host.
+func isGem(uri string) bool {
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+
+	return strings.EqualFold(path.Ext(uri), ".gem")
+}
@@ -0,0 +1,179 @@
+package bundler_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/cloudfoundry/bundler-cnb/bundler/fakes"
+	"github.com/cloudfoundry/packit/pexec"
+	"github.com/cloudfoundry/packit/postal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testGemDependencyManager(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		dependencies *fakes.DependencyManager
+		transport    *fakes.Transport
+		executable   *fakes.Executable
+		dependency   postal.Dependency
+		gemContent   string
+		manager      bundler.GemDependencyManager
+	)
+
+	it.Before(func() {
+		dependencies = &fakes.DependencyManager{}
+		transport = &fakes.Transport{}
+		executable = &fakes.Executable{}
+
+		gemContent = "some-gem-contents"
+		transport.DropCall.Stub = func(string, string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(gemContent)), nil
+		}
+
+		sum := sha256.Sum256([]byte("some-gem-contents"))
+		dependency = postal.Dependency{
+			ID:      "bundler",
+			SHA256:  hex.EncodeToString(sum[:]),
+			URI:     "https://example.com/bundler-2.2.0.gem?token=some-token",
+			Version: "2.2.0",
+		}
+
+		manager = bundler.NewGemDependencyManager(dependencies, transport, executable)
+	})
+
+	context("Resolve", func() {
+		it("delegates to the wrapped dependency manager", func() {
+			dependencies.ResolveCall.Returns.Dependency = dependency
+
+			resolved, err := manager.Resolve("/cnb/buildpack.toml", "bundler", "2.*", "some-stack")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolved).To(Equal(dependency))
+
+			Expect(dependencies.ResolveCall.Receives.Path).To(Equal("/cnb/buildpack.toml"))
+			Expect(dependencies.ResolveCall.Receives.Id).To(Equal("bundler"))
+			Expect(dependencies.ResolveCall.Receives.Version).To(Equal("2.*"))
+			Expect(dependencies.ResolveCall.Receives.Stack).To(Equal("some-stack"))
+		})
+	})
+
+	context("Install", func() {
+		context("when the dependency is a .gem file", func() {
+			var installed string
+
+			it.Before(func() {
+				executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+					content, err := ioutil.ReadFile(execution.Args[len(execution.Args)-1])
+					if err != nil {
+						return err
+					}
+
+					installed = string(content)
+					return nil
+				}
+			})
+
+			it("installs it with gem install", func() {
+				err := manager.Install(dependency, "/cnb", "/layers/bundler")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(transport.DropCall.Receives.Root).To(Equal("/cnb"))
+				Expect(transport.DropCall.Receives.Uri).To(Equal("https://example.com/bundler-2.2.0.gem?token=some-token"))
+
+				args := executable.ExecuteCall.Receives.Execution.Args
+				Expect(args[:len(args)-1]).To(Equal([]string{
+					"install", "--local",
+					"--install-dir", "/layers/bundler",
+					"--bindir", "/layers/bundler/bin",
+					"--no-document",
+				}))
+				Expect(filepath.Base(args[len(args)-1])).To(Equal("bundler-2.2.0.gem"))
+				Expect(args[len(args)-1]).NotTo(BeAnExistingFile())
+				Expect(installed).To(Equal("some-gem-contents"))
+
+				Expect(dependencies.InstallCall.CallCount).To(Equal(0))
+			})
+
+			context("failure cases", func() {
+				context("when the gem cannot be fetched", func() {
+					it.Before(func() {
+						transport.DropCall.Stub = nil
+						transport.DropCall.Returns.Error = errors.New("connection refused")
+					})
+
+					it("returns an error", func() {
+						err := manager.Install(dependency, "/cnb", "/layers/bundler")
+						Expect(err).To(MatchError("failed to fetch dependency: connection refused"))
+					})
+				})
+
+				context("when the checksum does not match", func() {
+					it.Before(func() {
+						gemContent = "some-corrupt-gem-contents"
+					})
+
+					it("returns an error without installing the gem", func() {
+						err := manager.Install(dependency, "/cnb", "/layers/bundler")
+						Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("checksum does not match: expected %s", dependency.SHA256))))
+
+						Expect(executable.ExecuteCall.CallCount).To(Equal(0))
+					})
+				})
+
+				context("when gem install fails", func() {
+					it.Before(func() {
+						executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+							fmt.Fprintln(execution.Stdout, "ERROR:  Could not find a valid gem")
+							return errors.New("exit status 2")
+						}
+					})
+
+					it("returns an error that includes the output", func() {
+						err := manager.Install(dependency, "/cnb", "/layers/bundler")
+						Expect(err).To(MatchError(ContainSubstring("failed to execute gem install: exit status 2")))
+						Expect(err).To(MatchError(ContainSubstring("Could not find a valid gem")))
+					})
+				})
+			})
+		})
+
+		context("when the dependency is a relative file:// .gem", func() {
+			it("installs it with gem install", func() {
+				dependency.URI = "file://bundler-2.2.0.gem"
+
+				err := manager.Install(dependency, "/cnb", "/layers/bundler")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(executable.ExecuteCall.CallCount).To(Equal(1))
+				Expect(dependencies.InstallCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the dependency is not a .gem file", func() {
+			it("delegates to the wrapped dependency manager", func() {
+				dependency.URI = "https://example.com/bundler-2.2.0.tgz"
+
+				err := manager.Install(dependency, "/cnb", "/layers/bundler")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dependencies.InstallCall.Receives.Dependency).To(Equal(dependency))
+				Expect(dependencies.InstallCall.Receives.CnbPath).To(Equal("/cnb"))
+				Expect(dependencies.InstallCall.Receives.LayerPath).To(Equal("/layers/bundler"))
+
+				Expect(transport.DropCall.CallCount).To(Equal(0))
+				Expect(executable.ExecuteCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+}
@@ -0,0 +1,194 @@
+package bundler
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	RubyGemsSource = "rubygems"
+	GitSource      = "git"
+	PathSource     = "path"
+)
+
+// Gem is a single spec locked in a Gemfile.lock.
+type Gem struct {
+	Name     string
+	Version  string
+	Platform string
+
+	// Source is one of rubygems, git or path, matching the Gemfile.lock
+	// section that the spec was listed under.
+	Source   string
+	Remote   string
+	Revision string
+}
+
+// PURL returns the package URL identifying the gem.
+func (g Gem) PURL() string {
+	purl := fmt.Sprintf("pkg:gem/%s@%s", g.Name, g.Version)
+
+	// Qualifiers are kept in lexical order as required by the purl spec.
+	var qualifiers []string
+	if g.Platform != "" {
+		qualifiers = append(qualifiers, fmt.Sprintf("platform=%s", url.QueryEscape(g.Platform)))
+	}
+
+	switch g.Source {
+	case RubyGemsSource:
+		if g.Remote != "" && strings.TrimSuffix(g.Remote, "/") != "https://rubygems.org" {
+			qualifiers = append(qualifiers, fmt.Sprintf("repository_url=%s", url.QueryEscape(g.Remote)))
+		}
+	case GitSource:
+		vcsURL := fmt.Sprintf("git+%s", g.Remote)
+		if g.Revision != "" {
+			vcsURL = fmt.Sprintf("%s@%s", vcsURL, g.Revision)
+		}
+		qualifiers = append(qualifiers, fmt.Sprintf("vcs_url=%s", url.QueryEscape(vcsURL)))
+	}
+
+	if len(qualifiers) > 0 {
+		purl = fmt.Sprintf("%s?%s", purl, strings.Join(qualifiers, "&"))
+	}
+
+	return purl
+}
+
+type GemfileLockParser struct{}
+
+func NewGemfileLockParser() GemfileLockParser {
+	return GemfileLockParser{}
+}
+
+func (p GemfileLockParser) ParseVersion(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+	defer file.Close()
+
+	var bundledWith bool
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if bundledWith {
+			return strings.TrimSpace(line), nil
+		}
+
+		if strings.TrimSpace(line) == "BUNDLED WITH" {
+			bundledWith = true
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+// ParseGems returns every spec locked in the Gemfile.lock at the given path,
+// or no gems when that file does not exist.
+func (p GemfileLockParser) ParseGems(path string) ([]Gem, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		gems    []Gem
+		current Gem
+		inSpecs bool
+	)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Section headers are not indented and reset the source of the specs
+		// that follow.
+		if !strings.HasPrefix(line, " ") {
+			inSpecs = false
+
+			switch strings.TrimSpace(line) {
+			case "GEM":
+				current = Gem{Source: RubyGemsSource}
+			case "GIT":
+				current = Gem{Source: GitSource}
+			case "PATH":
+				current = Gem{Source: PathSource}
+			default:
+				current = Gem{}
+			}
+
+			continue
+		}
+
+		if current.Source == "" {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "  remote: "):
+			current.Remote = strings.TrimPrefix(trimmed, "remote: ")
+		case strings.HasPrefix(line, "  revision: "):
+			current.Revision = strings.TrimPrefix(trimmed, "revision: ")
+		case line == "  specs:":
+			inSpecs = true
+
+		// Specs are indented by four spaces, their own dependencies by six.
+		case inSpecs && strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "     "):
+			gem, err := parseSpec(trimmed)
+			if err != nil {
+				return nil, err
+			}
+
+			gem.Source = current.Source
+			gem.Remote = current.Remote
+			gem.Revision = current.Revision
+			gems = append(gems, gem)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return gems, nil
+}
+
+func parseSpec(spec string) (Gem, error) {
+	fields := strings.SplitN(spec, " ", 2)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "(") || !strings.HasSuffix(fields[1], ")") {
+		return Gem{}, fmt.Errorf("failed to parse Gemfile.lock spec: %q", spec)
+	}
+
+	gem := Gem{Name: fields[0]}
+
+	// Gem versions cannot contain a hyphen, so anything after the first one is
+	// the platform the gem was built for.
+	version := strings.TrimSuffix(strings.TrimPrefix(fields[1], "("), ")")
+	parts := strings.SplitN(version, "-", 2)
+	gem.Version = parts[0]
+	if len(parts) == 2 {
+		gem.Platform = parts[1]
+	}
+
+	return gem, nil
+}
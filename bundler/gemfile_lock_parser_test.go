@@ -0,0 +1,219 @@
+package bundler_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testGemfileLockParser(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path   string
+		parser bundler.GemfileLockParser
+	)
+
+	it.Before(func() {
+		file, err := ioutil.TempFile("", "Gemfile.lock")
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		_, err = file.WriteString(`GIT
+  remote: https://github.com/some-org/some-git-gem.git
+  revision: some-revision
+  specs:
+    some-git-gem (0.1.0)
+      rack (>= 2.0)
+
+PATH
+  remote: .
+  specs:
+    some-path-gem (1.0.0)
+
+GEM
+  remote: https://rubygems.org/
+  specs:
+    citrus (3.0.2)
+    nokogiri (1.10.9-x86_64-linux)
+    rack (2.2.2)
+    toml-rb (2.0.1)
+      citrus (~> 3.0, > 3.0)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  toml-rb (= 2.0.1)
+
+BUNDLED WITH
+   1.17.3
+`)
+		Expect(err).NotTo(HaveOccurred())
+
+		path = file.Name()
+
+		parser = bundler.NewGemfileLockParser()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(path)).To(Succeed())
+	})
+
+	context("ParseVersion", func() {
+		it("parses the bundler version from a Gemfile.lock file", func() {
+			version, err := parser.ParseVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("1.17.3"))
+		})
+
+		context("when the Gemfile.lock file does not exist", func() {
+			it.Before(func() {
+				Expect(os.Remove(path)).To(Succeed())
+			})
+
+			it("returns an empty version", func() {
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("when the Gemfile.lock file does not have a BUNDLED WITH section", func() {
+			it.Before(func() {
+				err := ioutil.WriteFile(path, []byte("GEM\n  remote: https://rubygems.org/\n  specs:\n"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("returns an empty version", func() {
+				version, err := parser.ParseVersion(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(version).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the Gemfile.lock file cannot be read", func() {
+				it.Before(func() {
+					Expect(os.Chmod(path, 0000)).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Chmod(path, 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseVersion(path)
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+		})
+	})
+
+	context("ParseGems", func() {
+		it("parses every locked spec from a Gemfile.lock file", func() {
+			gems, err := parser.ParseGems(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gems).To(Equal([]bundler.Gem{
+				{
+					Name:     "some-git-gem",
+					Version:  "0.1.0",
+					Source:   "git",
+					Remote:   "https://github.com/some-org/some-git-gem.git",
+					Revision: "some-revision",
+				},
+				{
+					Name:    "some-path-gem",
+					Version: "1.0.0",
+					Source:  "path",
+					Remote:  ".",
+				},
+				{
+					Name:    "citrus",
+					Version: "3.0.2",
+					Source:  "rubygems",
+					Remote:  "https://rubygems.org/",
+				},
+				{
+					Name:     "nokogiri",
+					Version:  "1.10.9",
+					Platform: "x86_64-linux",
+					Source:   "rubygems",
+					Remote:   "https://rubygems.org/",
+				},
+				{
+					Name:    "rack",
+					Version: "2.2.2",
+					Source:  "rubygems",
+					Remote:  "https://rubygems.org/",
+				},
+				{
+					Name:    "toml-rb",
+					Version: "2.0.1",
+					Source:  "rubygems",
+					Remote:  "https://rubygems.org/",
+				},
+			}))
+		})
+
+		context("when the Gemfile.lock file does not exist", func() {
+			it.Before(func() {
+				Expect(os.Remove(path)).To(Succeed())
+			})
+
+			it("returns no gems", func() {
+				gems, err := parser.ParseGems(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(gems).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the Gemfile.lock file cannot be read", func() {
+				it.Before(func() {
+					Expect(os.Chmod(path, 0000)).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Chmod(path, 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseGems(path)
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+
+			context("when a spec is malformed", func() {
+				it.Before(func() {
+					err := ioutil.WriteFile(path, []byte("GEM\n  remote: https://rubygems.org/\n  specs:\n    rack 2.2.2\n"), 0644)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				it("returns an error", func() {
+					_, err := parser.ParseGems(path)
+					Expect(err).To(MatchError(`failed to parse Gemfile.lock spec: "rack 2.2.2"`))
+				})
+			})
+		})
+	})
+
+	context("Gem", func() {
+		context("PURL", func() {
+			it("includes a repository url for gems from other servers", func() {
+				gem := bundler.Gem{
+					Name:    "some-gem",
+					Version: "1.2.3",
+					Source:  "rubygems",
+					Remote:  "https://gems.example.com/",
+				}
+				Expect(gem.PURL()).To(Equal("pkg:gem/some-gem@1.2.3?repository_url=https%3A%2F%2Fgems.example.com%2F"))
+			})
+		})
+	})
+}
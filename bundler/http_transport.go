@@ -0,0 +1,86 @@
+package bundler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HTTPTransport fetches dependencies the way cargo.Transport does, opening
+// file:// uris relative to the buildpack and downloading everything else. It
+// also fails on unsuccessful responses and reports the size of downloads.
+type HTTPTransport struct {
+	client *http.Client
+}
+
+// NewHTTPTransport creates an HTTPTransport that downloads with the given
+// client.
+func NewHTTPTransport(client *http.Client) HTTPTransport {
+	return HTTPTransport{
+		client: client,
+	}
+}
+
+func (t HTTPTransport) Drop(root, uri string) (io.ReadCloser, error) {
+	if strings.HasPrefix(uri, "file://") {
+		file, err := os.Open(filepath.Join(root, strings.TrimPrefix(uri, "file://")))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+
+		return file, nil
+	}
+
+	request, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse request uri: %w", err)
+	}
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		response.Body.Close()
+		return nil, fmt.Errorf("failed to make request: unexpected status %s", response.Status)
+	}
+
+	return sizedReadCloser{
+		ReadCloser: response.Body,
+		size:       response.ContentLength,
+	}, nil
+}
+
+// sizedReadCloser is a download whose size is known before it is read, or -1
+// when the server did not send it.
+type sizedReadCloser struct {
+	io.ReadCloser
+
+	size int64
+}
+
+func (r sizedReadCloser) Size() int64 {
+	return r.size
+}
+
+// downloadSize returns the size of a download returned by a Transport, or -1
+// when it is not known up front.
+func downloadSize(download io.ReadCloser) int64 {
+	switch d := download.(type) {
+	case interface{ Size() int64 }:
+		return d.Size()
+	case *os.File:
+		info, err := d.Stat()
+		if err != nil {
+			return -1
+		}
+
+		return info.Size()
+	}
+
+	return -1
+}
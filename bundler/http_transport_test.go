@@ -0,0 +1,102 @@
+package bundler_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testHTTPTransport(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		cnbDir    string
+		server    *httptest.Server
+		transport bundler.HTTPTransport
+	)
+
+	it.Before(func() {
+		var err error
+		cnbDir, err = ioutil.TempDir("", "cnb")
+		Expect(err).NotTo(HaveOccurred())
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/some-dependency.tgz" {
+				http.NotFound(w, req)
+				return
+			}
+
+			fmt.Fprint(w, "some-dependency-contents")
+		}))
+
+		transport = bundler.NewHTTPTransport(http.DefaultClient)
+	})
+
+	it.After(func() {
+		server.Close()
+		Expect(os.RemoveAll(cnbDir)).To(Succeed())
+	})
+
+	context("Drop", func() {
+		it("downloads the uri and reports its size", func() {
+			bundle, err := transport.Drop(cnbDir, server.URL+"/some-dependency.tgz")
+			Expect(err).NotTo(HaveOccurred())
+			defer bundle.Close()
+
+			Expect(bundle.(interface{ Size() int64 }).Size()).To(Equal(int64(len("some-dependency-contents"))))
+
+			content, err := ioutil.ReadAll(bundle)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("some-dependency-contents"))
+		})
+
+		context("when the uri is a file:// uri", func() {
+			it.Before(func() {
+				err := ioutil.WriteFile(filepath.Join(cnbDir, "some-dependency.tgz"), []byte("some-file-contents"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("opens the file relative to the buildpack", func() {
+				bundle, err := transport.Drop(cnbDir, "file://some-dependency.tgz")
+				Expect(err).NotTo(HaveOccurred())
+				defer bundle.Close()
+
+				content, err := ioutil.ReadAll(bundle)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("some-file-contents"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the file does not exist", func() {
+				it("returns an error", func() {
+					_, err := transport.Drop(cnbDir, "file://missing.tgz")
+					Expect(err).To(MatchError(ContainSubstring("failed to open file:")))
+				})
+			})
+
+			context("when the request fails", func() {
+				it("returns an error", func() {
+					_, err := transport.Drop(cnbDir, "http://127.0.0.1:0/some-dependency.tgz")
+					Expect(err).To(MatchError(ContainSubstring("failed to make request:")))
+				})
+			})
+
+			context("when the response is unsuccessful", func() {
+				it("returns an error", func() {
+					_, err := transport.Drop(cnbDir, server.URL+"/missing.tgz")
+					Expect(err).To(MatchError("failed to make request: unexpected status 404 Not Found"))
+				})
+			})
+		})
+	})
+}
@@ -9,12 +9,23 @@ import (
 
 func TestUnitNode(t *testing.T) {
 	suite := spec.New("bundler", spec.Report(report.Terminal{}))
+	suite("BundleInstallProcess", testBundleInstallProcess)
+	suite("BuildpackConfig", testBuildpackConfig)
 	suite("BuildpackYMLParser", testBuildpackYMLParser)
+	suite("DeprecationPolicy", testDeprecationPolicy)
 	suite("Detect", testDetect)
+	suite("GemDependencyManager", testGemDependencyManager)
+	suite("GemfileLockParser", testGemfileLockParser)
+	suite("HTTPTransport", testHTTPTransport)
 	suite("LogEmitter", testLogEmitter)
 	suite("Clock", testClock)
+	suite("MirrorTransport", testMirrorTransport)
 	suite("PlanEntryResolver", testPlanEntryResolver)
 	suite("PlanRefinery", testPlanRefinery)
+	suite("ProgressTransport", testProgressTransport)
+	suite("RetryTransport", testRetryTransport)
+	suite("SBOMWriter", testSBOMWriter)
+	suite("ServiceBindingResolver", testServiceBindingResolver)
 	suite("Build", testBuild)
 	suite.Run(t)
 }
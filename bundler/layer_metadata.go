@@ -0,0 +1,36 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+)
+
+// layerReuseDiff explains why a cached layer with the given metadata cannot
+// be reused for a build that expects the given metadata. It returns no
+// reasons when the layer can be reused.
+func layerReuseDiff(cached map[string]interface{}, expected map[string]string) []string {
+	if len(cached) == 0 {
+		return []string{"layer metadata missing"}
+	}
+
+	var keys []string
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var reasons []string
+	for _, key := range keys {
+		value, ok := cached[key]
+		if !ok {
+			reasons = append(reasons, fmt.Sprintf("%s missing", key))
+			continue
+		}
+
+		if previous := fmt.Sprint(value); previous != expected[key] {
+			reasons = append(reasons, fmt.Sprintf("%s changed from %q to %q", key, previous, expected[key]))
+		}
+	}
+
+	return reasons
+}
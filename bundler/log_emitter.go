@@ -1,8 +1,13 @@
 package bundler
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cloudfoundry/packit"
@@ -10,25 +15,438 @@ import (
 	"github.com/cloudfoundry/packit/scribe"
 )
 
+const (
+	TextLogFormat = "text"
+	JSONLogFormat = "json"
+
+	DebugLogLevel = "debug"
+)
+
 type LogEmitter struct {
 	// Logger is embedded and therefore delegates all of its functions to the
 	// LogEmitter.
 	scribe.Logger
+
+	redactor *redactor
+	format   string
+	phase    string
+	debug    bool
 }
 
 func NewLogEmitter(output io.Writer) LogEmitter {
+	redactor := &redactor{writer: output}
+
 	return LogEmitter{
-		Logger: scribe.NewLogger(output),
+		Logger:   scribe.NewLogger(redactor),
+		redactor: redactor,
+		format:   TextLogFormat,
+	}
+}
+
+// NewJSONLogEmitter creates a LogEmitter that writes one JSON event per line
+// instead of indented text. Every event records the given phase, such as
+// detect or build.
+func NewJSONLogEmitter(output io.Writer, phase string) LogEmitter {
+	emitter := NewLogEmitter(output)
+	emitter.format = JSONLogFormat
+	emitter.phase = phase
+
+	return emitter
+}
+
+// WithLevel returns a copy of the LogEmitter that also prints Debug messages
+// when the level is DebugLogLevel.
+func (e LogEmitter) WithLevel(level string) LogEmitter {
+	e.debug = strings.EqualFold(strings.TrimSpace(level), DebugLogLevel)
+
+	return e
+}
+
+// Debugging reports whether Debug messages are printed, so that callers can
+// skip gathering details nobody will see.
+func (e LogEmitter) Debugging() bool {
+	return e.debug
+}
+
+// Debug prints a message only at the debug log level.
+func (e LogEmitter) Debug(format string, v ...interface{}) {
+	if !e.debug {
+		return
+	}
+
+	if e.structured() {
+		e.emit(logEvent{
+			Event:   "debug",
+			Message: fmt.Sprintf(format, v...),
+		})
+		return
+	}
+
+	e.Logger.Action("[debug] "+format, v...)
+}
+
+// ReuseInputs prints, at the debug log level, the cached and expected
+// metadata that decide whether the layer with the given name is reused.
+func (e LogEmitter) ReuseInputs(name string, cached map[string]interface{}, expected map[string]string) {
+	if !e.debug {
+		return
+	}
+
+	var keys []string
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	e.Debug("Reuse check for %s layer:", name)
+	for _, key := range keys {
+		previous := "<missing>"
+		if value, ok := cached[key]; ok {
+			previous = fmt.Sprintf("%q", fmt.Sprint(value))
+		}
+
+		e.Debug("%s: cached %s, expected %q", key, previous, expected[key])
 	}
 }
 
+// logEvent is a single line of JSON output. Fields that do not apply to an
+// event are omitted.
+type logEvent struct {
+	Phase           string             `json:"phase"`
+	Event           string             `json:"event"`
+	Message         string             `json:"message,omitempty"`
+	Name            string             `json:"name,omitempty"`
+	Version         string             `json:"version,omitempty"`
+	Source          string             `json:"source,omitempty"`
+	DeprecationDate string             `json:"deprecation_date,omitempty"`
+	Duration        *float64           `json:"duration,omitempty"`
+	URI             string             `json:"uri,omitempty"`
+	Size            *int64             `json:"size,omitempty"`
+	Downloaded      *int64             `json:"downloaded,omitempty"`
+	Throughput      *float64           `json:"throughput,omitempty"`
+	Offset          *int64             `json:"offset,omitempty"`
+	Attempt         int                `json:"attempt,omitempty"`
+	Attempts        int                `json:"attempts,omitempty"`
+	Error           string             `json:"error,omitempty"`
+	Candidates      []versionSourceLog `json:"candidates,omitempty"`
+	Requirements    []versionSourceLog `json:"requirements,omitempty"`
+	Conflicts       []versionSourceLog `json:"conflicts,omitempty"`
+	Ruby            string             `json:"ruby,omitempty"`
+	Incompatible    []incompatibleLog  `json:"incompatible,omitempty"`
+	Reasons         []string           `json:"reasons,omitempty"`
+	Binding         string             `json:"binding,omitempty"`
+	Host            string             `json:"host,omitempty"`
+	Env             string             `json:"env,omitempty"`
+	OriginalURI     string             `json:"original_uri,omitempty"`
+}
+
+type versionSourceLog struct {
+	Source   string `json:"source"`
+	Version  string `json:"version"`
+	Conflict bool   `json:"conflict,omitempty"`
+	Launch   bool   `json:"launch,omitempty"`
+}
+
+type incompatibleLog struct {
+	Version      string `json:"version"`
+	RubyVersions string `json:"ruby_versions"`
+}
+
+// emit writes the event in a single write so that it is redacted as a whole.
+func (e LogEmitter) emit(event logEvent) {
+	event.Phase = e.phase
+
+	content, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	_, _ = e.redactor.Write(append(content, '\n'))
+}
+
+func (e LogEmitter) structured() bool {
+	return e.format == JSONLogFormat
+}
+
+func (e LogEmitter) Title(format string, v ...interface{}) {
+	e.printf("title", e.Logger.Title, format, v...)
+}
+
+func (e LogEmitter) Process(format string, v ...interface{}) {
+	e.printf("process", e.Logger.Process, format, v...)
+}
+
+func (e LogEmitter) Subprocess(format string, v ...interface{}) {
+	e.printf("subprocess", e.Logger.Subprocess, format, v...)
+}
+
+func (e LogEmitter) Action(format string, v ...interface{}) {
+	e.printf("action", e.Logger.Action, format, v...)
+}
+
+func (e LogEmitter) Detail(format string, v ...interface{}) {
+	e.printf("detail", e.Logger.Detail, format, v...)
+}
+
+func (e LogEmitter) Subdetail(format string, v ...interface{}) {
+	e.printf("subdetail", e.Logger.Subdetail, format, v...)
+}
+
+// Break separates sections of text output and has no JSON equivalent.
+func (e LogEmitter) Break() {
+	if e.structured() {
+		return
+	}
+
+	e.Logger.Break()
+}
+
+func (e LogEmitter) printf(event string, print func(string, ...interface{}), format string, v ...interface{}) {
+	if !e.structured() {
+		print(format, v...)
+		return
+	}
+
+	e.emit(logEvent{
+		Event:   event,
+		Message: strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"),
+	})
+}
+
+// Completed reports how long a step took.
+func (e LogEmitter) Completed(duration time.Duration) {
+	if !e.structured() {
+		e.Action("Completed in %s", duration.Round(time.Millisecond))
+		return
+	}
+
+	seconds := duration.Seconds()
+	e.emit(logEvent{
+		Event:    "completed",
+		Duration: &seconds,
+	})
+}
+
+// Bar returns a progress bar that writes to the output of the LogEmitter.
+func (e LogEmitter) Bar() *scribe.Bar {
+	return scribe.NewBar(e.redactor)
+}
+
+// DownloadStarted prints the uri and the size of a download, which is
+// negative when it is not known.
+func (e LogEmitter) DownloadStarted(uri string, size int64) {
+	if e.structured() {
+		event := logEvent{
+			Event: "download-started",
+			URI:   uri,
+		}
+		if size >= 0 {
+			event.Size = &size
+		}
+
+		e.emit(event)
+		return
+	}
+
+	if size < 0 {
+		e.Action("Downloading %s", uri)
+		return
+	}
+
+	e.Action("Downloading %s (%s)", uri, formatBytes(size))
+}
+
+// DownloadProgress prints how much of a download has been read so far and
+// at what rate.
+func (e LogEmitter) DownloadProgress(downloaded, size int64, elapsed time.Duration) {
+	throughput := bytesPerSecond(downloaded, elapsed)
+
+	if e.structured() {
+		event := logEvent{
+			Event:      "download-progress",
+			Downloaded: &downloaded,
+			Throughput: &throughput,
+		}
+		if size >= 0 {
+			event.Size = &size
+		}
+
+		e.emit(event)
+		return
+	}
+
+	if size <= 0 {
+		e.Action("Downloaded %s at %s/s", formatBytes(downloaded), formatBytes(int64(throughput)))
+		return
+	}
+
+	e.Action("Downloaded %s of %s (%d%%) at %s/s", formatBytes(downloaded), formatBytes(size), downloaded*100/size, formatBytes(int64(throughput)))
+}
+
+// DownloadCompleted prints the size of a finished download and its average
+// throughput.
+func (e LogEmitter) DownloadCompleted(downloaded int64, elapsed time.Duration) {
+	throughput := bytesPerSecond(downloaded, elapsed)
+
+	if e.structured() {
+		seconds := elapsed.Seconds()
+		e.emit(logEvent{
+			Event:      "download-completed",
+			Downloaded: &downloaded,
+			Duration:   &seconds,
+			Throughput: &throughput,
+		})
+		return
+	}
+
+	e.Action("Downloaded %s in %s (%s/s)", formatBytes(downloaded), elapsed.Round(time.Millisecond), formatBytes(int64(throughput)))
+}
+
+// DownloadRetry prints why an attempt to download the uri failed and how long
+// it is until the next one.
+func (e LogEmitter) DownloadRetry(uri string, attempt, attempts int, err error, delay time.Duration) {
+	if e.structured() {
+		seconds := delay.Seconds()
+		e.emit(logEvent{
+			Event:    "download-retry",
+			URI:      uri,
+			Attempt:  attempt,
+			Attempts: attempts,
+			Error:    err.Error(),
+			Duration: &seconds,
+		})
+		return
+	}
+
+	e.Action("Attempt %d of %d to download %s failed: %s", attempt, attempts, uri, err)
+	e.Action("Retrying in %s", delay)
+}
+
+// DownloadResumed prints the byte from which a broken off download continues.
+func (e LogEmitter) DownloadResumed(uri string, offset int64) {
+	if e.structured() {
+		e.emit(logEvent{
+			Event:  "download-resumed",
+			URI:    uri,
+			Offset: &offset,
+		})
+		return
+	}
+
+	e.Action("Resuming download of %s from byte %d", uri, offset)
+}
+
+// LayerSize prints the size of a layer once its contents are installed.
+func (e LogEmitter) LayerSize(name string, size int64) {
+	if e.structured() {
+		e.emit(logEvent{
+			Event: "layer-size",
+			Name:  name,
+			Size:  &size,
+		})
+		return
+	}
+
+	e.Action("Installed %s layer size: %s", name, formatBytes(size))
+}
+
+// Requirements prints the Bundler versions requested during detection.
+func (e LogEmitter) Requirements(requirements []packit.BuildPlanRequirement) {
+	var logs []versionSourceLog
+	for _, requirement := range requirements {
+		metadata, _ := requirement.Metadata.(BuildPlanMetadata)
+
+		version := requirement.Version
+		if version == "" {
+			version = "*"
+		}
+
+		logs = append(logs, versionSourceLog{
+			Source:  metadata.VersionSource,
+			Version: version,
+			Launch:  metadata.Launch,
+		})
+	}
+
+	if e.structured() {
+		e.emit(logEvent{
+			Event:        "requirements",
+			Requirements: logs,
+		})
+		return
+	}
+
+	e.Process("Requesting Bundler versions:")
+	for _, log := range logs {
+		line := fmt.Sprintf("%q", log.Version)
+		if log.Source != "" {
+			line = fmt.Sprintf("%s (from %s)", line, log.Source)
+		}
+
+		e.Action("%s", line)
+	}
+	e.Break()
+}
+
+// Redact registers secrets that are masked in everything the LogEmitter
+// prints from then on, including the output of copies of the LogEmitter.
+func (e LogEmitter) Redact(secrets ...string) {
+	e.redactor.add(secrets...)
+}
+
+// RedactError masks the registered secrets in the message of an error that is
+// printed by packit rather than through the LogEmitter, such as one carrying
+// the output of bundle install.
+func (e LogEmitter) RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := e.redactor.redact(err.Error())
+	if message == err.Error() {
+		return err
+	}
+
+	return redactedError{err: err, message: message}
+}
+
+func (e LogEmitter) GemCredential(credential GemCredential) {
+	if e.structured() {
+		e.emit(logEvent{
+			Event:   "gem-credential",
+			Binding: credential.Binding,
+			Host:    credential.Host,
+			Env:     credential.EnvName(),
+		})
+		return
+	}
+
+	e.Subprocess("Configuring credentials for %s from binding %q", credential.Host, credential.Binding)
+	e.Action("Setting %s in the build environment", credential.EnvName())
+	e.Break()
+}
+
 func (e LogEmitter) SelectedDependency(entry packit.BuildpackPlanEntry, dependency postal.Dependency, now time.Time) {
 	source, ok := entry.Metadata["version-source"].(string)
 	if !ok {
 		source = "<unknown>"
 	}
 
-	e.Subprocess("Selected %s version (using %s): %s", dependency.Name, source, dependency.Version)
+	if e.structured() {
+		event := logEvent{
+			Event:   "selected-dependency",
+			Name:    dependency.Name,
+			Version: dependency.Version,
+			Source:  source,
+		}
+		if (dependency.DeprecationDate != time.Time{}) {
+			event.DeprecationDate = dependency.DeprecationDate.Format("2006-01-02")
+		}
+
+		e.emit(event)
+	} else {
+		e.Subprocess("Selected %s version (using %s): %s", dependency.Name, source, dependency.Version)
+	}
 
 	if (dependency.DeprecationDate != time.Time{}) {
 		deprecationDate := dependency.DeprecationDate
@@ -44,19 +462,79 @@ func (e LogEmitter) SelectedDependency(entry packit.BuildpackPlanEntry, dependen
 	e.Break()
 }
 
-func (e LogEmitter) Candidates(entries []packit.BuildpackPlanEntry) {
-	e.Subprocess("Candidate version sources (in priority order):")
+func (e LogEmitter) IncompatibleDependencies(ruby, source string, dependencies []Dependency) {
+	if e.structured() {
+		var incompatible []incompatibleLog
+		for _, dependency := range dependencies {
+			incompatible = append(incompatible, incompatibleLog{
+				Version:      dependency.Version,
+				RubyVersions: dependency.RubyVersions,
+			})
+		}
 
+		e.emit(logEvent{
+			Event:        "incompatible-dependencies",
+			Ruby:         ruby,
+			Source:       source,
+			Incompatible: incompatible,
+		})
+		return
+	}
+
+	e.Subprocess("Excluding versions incompatible with Ruby %s (from %s):", ruby, source)
+	for _, dependency := range dependencies {
+		e.Action("%s requires Ruby %s", dependency.Version, dependency.RubyVersions)
+	}
+	e.Break()
+}
+
+// LayerNotReused explains why the cached layer with the given name has to be
+// rebuilt.
+func (e LogEmitter) LayerNotReused(name string, reasons []string) {
+	if e.structured() {
+		e.emit(logEvent{
+			Event:   "layer-not-reused",
+			Name:    name,
+			Reasons: reasons,
+		})
+		return
+	}
+
+	e.Subprocess("Cached %s layer not reused:", name)
+	for _, reason := range reasons {
+		e.Action("%s", reason)
+	}
+	e.Break()
+}
+
+func (e LogEmitter) DependencyMapping(binding string, dependency postal.Dependency, uri string) {
+	if e.structured() {
+		e.emit(logEvent{
+			Event:       "dependency-mapping",
+			Binding:     binding,
+			Name:        dependency.Name,
+			Version:     dependency.Version,
+			URI:         uri,
+			OriginalURI: dependency.URI,
+		})
+		return
+	}
+
+	e.Subprocess("Applying dependency mapping from binding %q", binding)
+	e.Action("%s %s: %s -> %s", dependency.Name, dependency.Version, dependency.URI, uri)
+	e.Break()
+}
+
+// Candidates prints the entries in priority order, marking the ones whose
+// version constraint conflicts with those of higher priority.
+func (e LogEmitter) Candidates(entries []packit.BuildpackPlanEntry, conflicts map[int]bool) {
 	var (
-		sources [][2]string
+		sources []versionSourceLog
 		maxLen  int
 	)
 
-	for _, entry := range entries {
-		versionSource, ok := entry.Metadata["version-source"].(string)
-		if !ok {
-			versionSource = "<unknown>"
-		}
+	for i, entry := range entries {
+		versionSource := versionSource(entry)
 
 		if len(versionSource) > maxLen {
 			maxLen = len(versionSource)
@@ -66,12 +544,152 @@ func (e LogEmitter) Candidates(entries []packit.BuildpackPlanEntry) {
 			entry.Version = "*"
 		}
 
-		sources = append(sources, [2]string{versionSource, entry.Version})
+		sources = append(sources, versionSourceLog{
+			Source:   versionSource,
+			Version:  entry.Version,
+			Conflict: conflicts[i],
+		})
+	}
+
+	if e.structured() {
+		e.emit(logEvent{
+			Event:      "candidates",
+			Candidates: sources,
+		})
+		return
 	}
 
+	e.Subprocess("Candidate version sources (in priority order):")
+
 	for _, source := range sources {
-		e.Action(("%-" + strconv.Itoa(maxLen) + "s -> %q"), source[0], source[1])
+		line := fmt.Sprintf(("%-" + strconv.Itoa(maxLen) + "s -> %q"), source.Source, source.Version)
+		if source.Conflict {
+			line = fmt.Sprintf("%s (conflict)", line)
+		}
+
+		e.Action("%s", line)
 	}
 
 	e.Break()
 }
+
+// VersionConflicts warns that the version constraints of the conflicting
+// entries are ignored in favour of the chosen entry.
+func (e LogEmitter) VersionConflicts(chosen packit.BuildpackPlanEntry, conflicts []packit.BuildpackPlanEntry) {
+	if e.structured() {
+		var sources []versionSourceLog
+		for _, conflict := range conflicts {
+			sources = append(sources, versionSourceLog{
+				Source:  versionSource(conflict),
+				Version: conflict.Version,
+			})
+		}
+
+		e.emit(logEvent{
+			Event:     "version-conflicts",
+			Version:   chosen.Version,
+			Source:    versionSource(chosen),
+			Conflicts: sources,
+		})
+		return
+	}
+
+	e.Subprocess("WARNING: version constraints conflict with %q from %s:", chosen.Version, versionSource(chosen))
+	for _, conflict := range conflicts {
+		e.Action("%q from %s is ignored", conflict.Version, versionSource(conflict))
+	}
+	e.Break()
+}
+
+// redactor masks registered secrets in everything written through it. Each
+// scribe.Logger call results in a single write, so secrets are never split
+// across writes.
+type redactor struct {
+	sync.Mutex
+
+	writer  io.Writer
+	secrets []string
+}
+
+// add registers the secrets along with the form they take in JSON output,
+// where characters such as & and " are escaped.
+func (r *redactor) add(secrets ...string) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+
+		r.secrets = append(r.secrets, secret)
+
+		content, err := json.Marshal(secret)
+		if err != nil {
+			continue
+		}
+
+		if escaped := string(content[1 : len(content)-1]); escaped != secret {
+			r.secrets = append(r.secrets, escaped)
+		}
+	}
+}
+
+func (r *redactor) redact(output string) string {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, secret := range r.secrets {
+		output = strings.ReplaceAll(output, secret, "[REDACTED]")
+	}
+
+	return output
+}
+
+func (r *redactor) Write(p []byte) (int, error) {
+	_, err := io.WriteString(r.writer, r.redact(string(p)))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// redactedError is an error whose message has its secrets masked. The
+// original error is still available through errors.Is and errors.As.
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e redactedError) Error() string {
+	return e.message
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// formatBytes formats a number of bytes using binary units.
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func bytesPerSecond(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(bytes) / elapsed.Seconds()
+}
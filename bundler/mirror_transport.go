@@ -0,0 +1,169 @@
+package bundler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const UpstreamDependencyHost = "buildpacks.cloudfoundry.org"
+
+//go:generate faux --interface Transport --output fakes/transport.go
+type Transport interface {
+	Drop(root, uri string) (io.ReadCloser, error)
+}
+
+// MirrorTransport fetches dependencies hosted on buildpacks.cloudfoundry.org
+// from a mirror instead, falling back to the upstream uri when the mirror
+// cannot serve a download matching the checksum in buildpack.toml.
+type MirrorTransport struct {
+	transport Transport
+	mirror    string
+	configs   *buildpackConfigCache
+}
+
+// NewMirrorTransport creates a MirrorTransport that delegates to the given
+// Transport. The mirror is an http://, https:// or file:// base uri; when it
+// is empty every uri is handed to the given Transport unchanged.
+func NewMirrorTransport(transport Transport, mirror string) MirrorTransport {
+	return MirrorTransport{
+		transport: transport,
+		mirror:    strings.TrimSuffix(mirror, "/"),
+		configs:   newBuildpackConfigCache(),
+	}
+}
+
+func (t MirrorTransport) Drop(root, uri string) (io.ReadCloser, error) {
+	mirrored, ok, err := t.rewrite(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return t.transport.Drop(root, uri)
+	}
+
+	config, err := t.configs.get(filepath.Join(root, "buildpack.toml"))
+	if err != nil {
+		return nil, err
+	}
+	checksum := lookupChecksum(config, uri)
+
+	var failures []string
+	for _, candidate := range []string{mirrored, uri} {
+		bundle, err := t.fetch(root, candidate, checksum)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", candidate, err))
+			continue
+		}
+
+		return bundle, nil
+	}
+
+	return nil, fmt.Errorf("failed to fetch dependency from any source:\n%s", strings.Join(failures, "\n"))
+}
+
+// rewrite returns the mirror location of an upstream uri and whether the uri
+// should be fetched from the mirror at all.
+func (t MirrorTransport) rewrite(uri string) (string, bool, error) {
+	if t.mirror == "" {
+		return "", false, nil
+	}
+
+	upstream, err := url.Parse(uri)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to parse dependency uri: %w", err)
+	}
+
+	if upstream.Host != UpstreamDependencyHost {
+		return "", false, nil
+	}
+
+	mirrored := t.mirror + upstream.Path
+	if upstream.RawQuery != "" {
+		mirrored = fmt.Sprintf("%s?%s", mirrored, upstream.RawQuery)
+	}
+
+	return mirrored, true, nil
+}
+
+// fetch downloads the uri into a temporary file so that its checksum can be
+// verified before any of it is handed to the caller.
+func (t MirrorTransport) fetch(root, uri, checksum string) (io.ReadCloser, error) {
+	var (
+		bundle io.ReadCloser
+		err    error
+	)
+
+	// The wrapped transport resolves file:// uris relative to the buildpack,
+	// whereas a file:// mirror is an absolute location on the build host.
+	if strings.HasPrefix(uri, "file://") {
+		bundle, err = os.Open(strings.TrimPrefix(uri, "file://"))
+	} else {
+		bundle, err = t.transport.Drop(root, uri)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer bundle.Close()
+
+	file, err := ioutil.TempFile("", "dependency")
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), bundle)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); checksum != "" && sum != checksum {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("checksum does not match: expected %s, got %s", checksum, sum)
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return tempFile{file}, nil
+}
+
+// lookupChecksum finds the checksum that buildpack.toml declares for the
+// dependency with the given uri, returning an empty string if there is none.
+func lookupChecksum(config BuildpackConfig, uri string) string {
+	for _, dependency := range config.Dependencies {
+		if dependency.URI == uri {
+			return dependency.SHA256
+		}
+	}
+
+	return ""
+}
+
+// tempFile removes the underlying file once it has been closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,210 @@
+package bundler_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/cloudfoundry/bundler-cnb/bundler/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testMirrorTransport(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		cnbDir        string
+		upstreamURI   string
+		mirrorContent string
+		mirrorServer  *httptest.Server
+		transport     *fakes.Transport
+	)
+
+	it.Before(func() {
+		var err error
+		cnbDir, err = ioutil.TempDir("", "cnb")
+		Expect(err).NotTo(HaveOccurred())
+
+		upstreamURI = "https://buildpacks.cloudfoundry.org/dependencies/bundler/bundler-2.1.4.tgz"
+
+		sum := sha256.Sum256([]byte("some-dependency-contents"))
+		err = ioutil.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte(fmt.Sprintf(`
+[[metadata.dependencies]]
+  id = "bundler"
+  sha256 = %q
+  uri = %q
+  version = "2.1.4"
+`, hex.EncodeToString(sum[:]), upstreamURI)), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		mirrorContent = "some-dependency-contents"
+		mirrorServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/some-mirror/dependencies/bundler/bundler-2.1.4.tgz" {
+				http.NotFound(w, req)
+				return
+			}
+
+			fmt.Fprint(w, mirrorContent)
+		}))
+
+		transport = &fakes.Transport{}
+		transport.DropCall.Stub = func(root, uri string) (io.ReadCloser, error) {
+			if strings.HasPrefix(uri, mirrorServer.URL) {
+				resp, err := http.Get(uri)
+				if err != nil {
+					return nil, err
+				}
+
+				if resp.StatusCode != http.StatusOK {
+					resp.Body.Close()
+					return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+				}
+
+				return resp.Body, nil
+			}
+
+			return ioutil.NopCloser(strings.NewReader("some-dependency-contents")), nil
+		}
+	})
+
+	it.After(func() {
+		mirrorServer.Close()
+		Expect(os.RemoveAll(cnbDir)).To(Succeed())
+	})
+
+	context("Drop", func() {
+		context("when no mirror is configured", func() {
+			it("delegates to the wrapped transport", func() {
+				bundle, err := bundler.NewMirrorTransport(transport, "").Drop(cnbDir, upstreamURI)
+				Expect(err).NotTo(HaveOccurred())
+				defer bundle.Close()
+
+				Expect(transport.DropCall.CallCount).To(Equal(1))
+				Expect(transport.DropCall.Receives.Root).To(Equal(cnbDir))
+				Expect(transport.DropCall.Receives.Uri).To(Equal(upstreamURI))
+			})
+		})
+
+		context("when an http mirror is configured", func() {
+			it("fetches upstream dependencies from the mirror", func() {
+				bundle, err := bundler.NewMirrorTransport(transport, mirrorServer.URL+"/some-mirror/").Drop(cnbDir, upstreamURI)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := ioutil.ReadAll(bundle)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("some-dependency-contents"))
+				Expect(bundle.Close()).To(Succeed())
+
+				Expect(transport.DropCall.CallCount).To(Equal(1))
+				Expect(transport.DropCall.Receives.Uri).To(Equal(mirrorServer.URL + "/some-mirror/dependencies/bundler/bundler-2.1.4.tgz"))
+			})
+
+			it("does not rewrite uris from other hosts", func() {
+				bundle, err := bundler.NewMirrorTransport(transport, mirrorServer.URL+"/some-mirror").Drop(cnbDir, "https://example.com/some-dependency.tgz")
+				Expect(err).NotTo(HaveOccurred())
+				defer bundle.Close()
+
+				Expect(transport.DropCall.Receives.Uri).To(Equal("https://example.com/some-dependency.tgz"))
+			})
+
+			context("when the mirror serves a download that does not match the checksum", func() {
+				it.Before(func() {
+					mirrorContent = "some-corrupt-contents"
+				})
+
+				it("falls back to the upstream uri", func() {
+					bundle, err := bundler.NewMirrorTransport(transport, mirrorServer.URL+"/some-mirror").Drop(cnbDir, upstreamURI)
+					Expect(err).NotTo(HaveOccurred())
+
+					content, err := ioutil.ReadAll(bundle)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal("some-dependency-contents"))
+					Expect(bundle.Close()).To(Succeed())
+
+					Expect(transport.DropCall.CallCount).To(Equal(2))
+					Expect(transport.DropCall.Receives.Uri).To(Equal(upstreamURI))
+				})
+			})
+
+			context("when the mirror does not have the dependency", func() {
+				it("falls back to the upstream uri", func() {
+					bundle, err := bundler.NewMirrorTransport(transport, mirrorServer.URL+"/other-mirror").Drop(cnbDir, upstreamURI)
+					Expect(err).NotTo(HaveOccurred())
+					defer bundle.Close()
+
+					Expect(transport.DropCall.CallCount).To(Equal(2))
+					Expect(transport.DropCall.Receives.Uri).To(Equal(upstreamURI))
+				})
+			})
+		})
+
+		context("when a file mirror is configured", func() {
+			var mirrorDir string
+
+			it.Before(func() {
+				var err error
+				mirrorDir, err = ioutil.TempDir("", "mirror")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(os.MkdirAll(filepath.Join(mirrorDir, "dependencies", "bundler"), os.ModePerm)).To(Succeed())
+				err = ioutil.WriteFile(filepath.Join(mirrorDir, "dependencies", "bundler", "bundler-2.1.4.tgz"), []byte("some-dependency-contents"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(mirrorDir)).To(Succeed())
+			})
+
+			it("reads upstream dependencies from the mirror directory", func() {
+				bundle, err := bundler.NewMirrorTransport(transport, "file://"+mirrorDir).Drop(cnbDir, upstreamURI)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := ioutil.ReadAll(bundle)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("some-dependency-contents"))
+				Expect(bundle.Close()).To(Succeed())
+
+				Expect(transport.DropCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when every source fails", func() {
+				it.Before(func() {
+					transport.DropCall.Stub = nil
+					transport.DropCall.Returns.Error = errors.New("some-network-error")
+				})
+
+				it("returns an error listing each attempt", func() {
+					_, err := bundler.NewMirrorTransport(transport, "file:///no/such/mirror").Drop(cnbDir, upstreamURI)
+					Expect(err).To(MatchError(ContainSubstring("failed to fetch dependency from any source")))
+					Expect(err).To(MatchError(ContainSubstring("file:///no/such/mirror/dependencies/bundler/bundler-2.1.4.tgz: open /no/such/mirror/dependencies/bundler/bundler-2.1.4.tgz: no such file or directory")))
+					Expect(err).To(MatchError(ContainSubstring(upstreamURI + ": some-network-error")))
+				})
+			})
+
+			context("when the buildpack.toml cannot be parsed", func() {
+				it.Before(func() {
+					Expect(ioutil.WriteFile(filepath.Join(cnbDir, "buildpack.toml"), []byte("%%%"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := bundler.NewMirrorTransport(transport, "file:///some/mirror").Drop(cnbDir, upstreamURI)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse buildpack.toml")))
+				})
+			})
+		})
+	})
+}
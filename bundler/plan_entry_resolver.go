@@ -1,30 +1,65 @@
 package bundler
 
 import (
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/cloudfoundry/packit"
 )
 
+const (
+	// ConflictFail fails the build when a version constraint from the plan
+	// cannot be satisfied together with the selected one.
+	ConflictFail = "fail"
+
+	// ConflictWarn only warns about those constraints.
+	ConflictWarn = "warn"
+)
+
 type PlanEntryResolver struct {
-	logger LogEmitter
+	logger         LogEmitter
+	conflictPolicy string
+	priorities     string
 }
 
-func NewPlanEntryResolver(logger LogEmitter) PlanEntryResolver {
+// NewPlanEntryResolver creates a PlanEntryResolver that handles conflicting
+// version constraints according to the given policy, which is either
+// ConflictFail or ConflictWarn. An empty policy defaults to ConflictWarn, as
+// BP_BUNDLER_VERSION and buildpack.yml are meant to override the major
+// version pinned by a Gemfile.lock.
+//
+// The priorities are a comma separated list of source=priority pairs that
+// override the version source priorities declared in buildpack.toml.
+func NewPlanEntryResolver(logger LogEmitter, conflictPolicy, priorities string) PlanEntryResolver {
 	return PlanEntryResolver{
-		logger: logger,
+		logger:         logger,
+		conflictPolicy: conflictPolicy,
+		priorities:     priorities,
 	}
 }
 
-func (r PlanEntryResolver) Resolve(entries []packit.BuildpackPlanEntry) packit.BuildpackPlanEntry {
-	var (
-		priorities = map[string]int{
-			"buildpack.yml": 3,
-			"":              -1,
-		}
-	)
+// Resolve selects the plan entry whose version source has the highest
+// priority, using the priorities declared in the given buildpack.toml.
+func (r PlanEntryResolver) Resolve(config BuildpackConfig, entries []packit.BuildpackPlanEntry) (packit.BuildpackPlanEntry, error) {
+	priorities, err := r.versionSourcePriorities(config)
+	if err != nil {
+		return packit.BuildpackPlanEntry{}, err
+	}
 
-	sort.Slice(entries, func(i, j int) bool {
+	policy := strings.ToLower(strings.TrimSpace(r.conflictPolicy))
+	if policy == "" {
+		policy = ConflictWarn
+	}
+
+	if policy != ConflictFail && policy != ConflictWarn {
+		return packit.BuildpackPlanEntry{}, fmt.Errorf("invalid version conflict policy %q: must be %q or %q", r.conflictPolicy, ConflictFail, ConflictWarn)
+	}
+
+	// Entries from the same source keep the order in which they appear in the
+	// plan so that the outcome does not depend on the sort implementation.
+	sort.SliceStable(entries, func(i, j int) bool {
 		leftSource := entries[i].Metadata["version-source"]
 		left, _ := leftSource.(string)
 
@@ -36,8 +71,54 @@ func (r PlanEntryResolver) Resolve(entries []packit.BuildpackPlanEntry) packit.B
 
 	chosenEntry := entries[0]
 
-	if chosenEntry.Metadata == nil {
-		chosenEntry.Metadata = map[string]interface{}{}
+	// Constraints are intersected in priority order, starting from the one of
+	// the chosen entry, and any constraint that cannot be satisfied together
+	// with the ones before it is a conflict.
+	var (
+		constraints []string
+		conflicts   = map[int]bool{}
+	)
+
+	for i, entry := range entries {
+		if entry.Version == "" || entry.Version == "default" {
+			continue
+		}
+
+		ok, known := satisfiable(append(constraints, entry.Version)...)
+		if !known {
+			// Dropping the chosen constraint would resolve a version from the
+			// entries of lower priority instead.
+			if i == 0 {
+				return packit.BuildpackPlanEntry{}, fmt.Errorf("failed to parse version constraint %q from %s", entry.Version, versionSource(entry))
+			}
+
+			continue
+		}
+
+		if !ok {
+			conflicts[i] = true
+			continue
+		}
+
+		constraints = append(constraints, entry.Version)
+	}
+
+	metadata := map[string]interface{}{}
+	for key, value := range chosenEntry.Metadata {
+		metadata[key] = value
+	}
+	chosenEntry.Metadata = metadata
+
+	// Narrowing the chosen version to the intersection makes the resolved
+	// dependency satisfy every compatible constraint, including those of
+	// lower priority when the chosen entry has no version. Constraints
+	// containing || cannot be joined with a comma without changing their
+	// meaning, so only the one of highest priority is kept.
+	if len(constraints) > 0 {
+		chosenEntry.Version = constraints[0]
+		if !strings.Contains(strings.Join(constraints, ""), "||") {
+			chosenEntry.Version = strings.Join(constraints, ", ")
+		}
 	}
 
 	for _, entry := range entries {
@@ -49,7 +130,72 @@ func (r PlanEntryResolver) Resolve(entries []packit.BuildpackPlanEntry) packit.B
 		}
 	}
 
-	r.logger.Candidates(entries)
+	r.logger.Candidates(entries, conflicts)
+
+	if len(conflicts) > 0 {
+		var (
+			conflicting []packit.BuildpackPlanEntry
+			messages    []string
+		)
+		for i, entry := range entries {
+			if conflicts[i] {
+				conflicting = append(conflicting, entry)
+				messages = append(messages, fmt.Sprintf("%q from %s", entry.Version, versionSource(entry)))
+			}
+		}
+
+		if policy == ConflictFail {
+			return packit.BuildpackPlanEntry{}, fmt.Errorf("failed to satisfy version constraints: %s cannot be satisfied together with %q from %s", strings.Join(messages, ", "), entries[0].Version, versionSource(entries[0]))
+		}
+
+		r.logger.VersionConflicts(entries[0], conflicting)
+	}
+
+	return chosenEntry, nil
+}
+
+// versionSourcePriorities returns the built-in priorities, overridden by the
+// [metadata.version-source-priorities] table in buildpack.toml and then by the
+// priorities given to the PlanEntryResolver. Sources that are not listed
+// anywhere have a priority of 0.
+func (r PlanEntryResolver) versionSourcePriorities(config BuildpackConfig) (map[string]int, error) {
+	priorities := map[string]int{
+		"BP_BUNDLER_VERSION": 4,
+		"buildpack.yml":      3,
+		"Gemfile.lock":       2,
+		"":                   -1,
+	}
+
+	for source, priority := range config.VersionSourcePriorities {
+		priorities[source] = priority
+	}
+
+	for _, pair := range strings.Split(r.priorities, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid version source priority %q: must be of the form source=priority", pair)
+		}
+
+		priority, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid version source priority %q: %w", pair, err)
+		}
+
+		priorities[strings.TrimSpace(parts[0])] = priority
+	}
+
+	return priorities, nil
+}
+
+func versionSource(entry packit.BuildpackPlanEntry) string {
+	source, ok := entry.Metadata["version-source"].(string)
+	if !ok {
+		return "<unknown>"
+	}
 
-	return chosenEntry
+	return source
 }
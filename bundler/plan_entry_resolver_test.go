@@ -15,51 +15,154 @@ func testPlanEntryResolver(t *testing.T, context spec.G, it spec.S) {
 	var (
 		Expect = NewWithT(t).Expect
 
+		config   bundler.BuildpackConfig
 		buffer   *bytes.Buffer
 		resolver bundler.PlanEntryResolver
 	)
 
 	it.Before(func() {
+		config = bundler.BuildpackConfig{}
+
 		buffer = bytes.NewBuffer(nil)
-		resolver = bundler.NewPlanEntryResolver(bundler.NewLogEmitter(buffer))
+		resolver = bundler.NewPlanEntryResolver(bundler.NewLogEmitter(buffer), "", "")
 	})
 
 	context("when a buildpack.yml entry is included", func() {
 		it("resolves the best plan entry", func() {
-			entry := resolver.Resolve([]packit.BuildpackPlanEntry{
+			entry, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
 				{
 					Name:    "bundler",
 					Version: "other-version",
 				},
 				{
 					Name:    "bundler",
-					Version: "buildpack-yml-version",
+					Version: "2.1.4",
 					Metadata: map[string]interface{}{
 						"version-source": "buildpack.yml",
 					},
 				},
 			})
+			Expect(err).NotTo(HaveOccurred())
 			Expect(entry).To(Equal(packit.BuildpackPlanEntry{
 				Name:    "bundler",
-				Version: "buildpack-yml-version",
+				Version: "2.1.4",
 				Metadata: map[string]interface{}{
 					"version-source": "buildpack.yml",
 				},
 			}))
 
 			Expect(buffer.String()).To(ContainSubstring("    Candidate version sources (in priority order):"))
-			Expect(buffer.String()).To(ContainSubstring("      buildpack.yml -> \"buildpack-yml-version\""))
+			Expect(buffer.String()).To(ContainSubstring("      buildpack.yml -> \"2.1.4\""))
 			Expect(buffer.String()).To(ContainSubstring("      <unknown>     -> \"other-version\""))
 		})
 	})
 
+	context("when a Gemfile.lock entry is included", func() {
+		it("resolves the best plan entry", func() {
+			entry, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+				{
+					Name:    "bundler",
+					Version: "other-version",
+				},
+				{
+					Name:    "bundler",
+					Version: "gemfile-lock-version",
+					Metadata: map[string]interface{}{
+						"version-source": "Gemfile.lock",
+					},
+				},
+				{
+					Name:    "bundler",
+					Version: "2.1.4",
+					Metadata: map[string]interface{}{
+						"version-source": "buildpack.yml",
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry).To(Equal(packit.BuildpackPlanEntry{
+				Name:    "bundler",
+				Version: "2.1.4",
+				Metadata: map[string]interface{}{
+					"version-source": "buildpack.yml",
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("    Candidate version sources (in priority order):"))
+			Expect(buffer.String()).To(ContainSubstring("      buildpack.yml -> \"2.1.4\"\n      Gemfile.lock  -> \"gemfile-lock-version\"\n      <unknown>     -> \"other-version\""))
+		})
+	})
+
+	context("when a BP_BUNDLER_VERSION entry is included", func() {
+		it("resolves the best plan entry", func() {
+			entry, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+				{
+					Name:    "bundler",
+					Version: "buildpack-yml-version",
+					Metadata: map[string]interface{}{
+						"version-source": "buildpack.yml",
+					},
+				},
+				{
+					Name:    "bundler",
+					Version: "2.2.0",
+					Metadata: map[string]interface{}{
+						"version-source": "BP_BUNDLER_VERSION",
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry).To(Equal(packit.BuildpackPlanEntry{
+				Name:    "bundler",
+				Version: "2.2.0",
+				Metadata: map[string]interface{}{
+					"version-source": "BP_BUNDLER_VERSION",
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("    Candidate version sources (in priority order):"))
+			Expect(buffer.String()).To(ContainSubstring("      BP_BUNDLER_VERSION -> \"2.2.0\"\n      buildpack.yml      -> \"buildpack-yml-version\""))
+		})
+	})
+
+	context("when BP_BUNDLER_VERSION overrides the major version of a Gemfile.lock", func() {
+		it("resolves the BP_BUNDLER_VERSION entry", func() {
+			entry, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+				{
+					Name:    "bundler",
+					Version: "1.*.*",
+					Metadata: map[string]interface{}{
+						"version-source": "Gemfile.lock",
+					},
+				},
+				{
+					Name:    "bundler",
+					Version: "2.1.4",
+					Metadata: map[string]interface{}{
+						"version-source": "BP_BUNDLER_VERSION",
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry).To(Equal(packit.BuildpackPlanEntry{
+				Name:    "bundler",
+				Version: "2.1.4",
+				Metadata: map[string]interface{}{
+					"version-source": "BP_BUNDLER_VERSION",
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("    WARNING: version constraints conflict with \"2.1.4\" from BP_BUNDLER_VERSION:\n      \"1.*.*\" from Gemfile.lock is ignored\n"))
+		})
+	})
+
 	context("when entry flags differ", func() {
 		context("OR's them together on best plan entry", func() {
 			it("has all flags", func() {
-				entry := resolver.Resolve([]packit.BuildpackPlanEntry{
+				entry, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
 					{
 						Name:    "bundler",
-						Version: "buildpack-yml-version",
+						Version: "2.1.4",
 						Metadata: map[string]interface{}{
 							"version-source": "buildpack.yml",
 						},
@@ -72,9 +175,10 @@ func testPlanEntryResolver(t *testing.T, context spec.G, it spec.S) {
 						},
 					},
 				})
+				Expect(err).NotTo(HaveOccurred())
 				Expect(entry).To(Equal(packit.BuildpackPlanEntry{
 					Name:    "bundler",
-					Version: "buildpack-yml-version",
+					Version: "2.1.4",
 					Metadata: map[string]interface{}{
 						"version-source": "buildpack.yml",
 						"build":          true,
@@ -86,17 +190,270 @@ func testPlanEntryResolver(t *testing.T, context spec.G, it spec.S) {
 
 	context("when an unknown source entry is included", func() {
 		it("resolves the best plan entry", func() {
-			entry := resolver.Resolve([]packit.BuildpackPlanEntry{
+			entry, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
 				{
 					Name:    "bundler",
-					Version: "other-version",
+					Version: "1.17.3",
 				},
 			})
+			Expect(err).NotTo(HaveOccurred())
 			Expect(entry).To(Equal(packit.BuildpackPlanEntry{
 				Name:     "bundler",
-				Version:  "other-version",
+				Version:  "1.17.3",
 				Metadata: map[string]interface{}{},
 			}))
 		})
 	})
+
+	context("when several entries come from the same source", func() {
+		it("keeps the order in which they appear in the plan", func() {
+			entry, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+				{
+					Name:    "bundler",
+					Version: "2.x.x",
+				},
+				{
+					Name:    "bundler",
+					Version: "2.*.*",
+					Metadata: map[string]interface{}{
+						"version-source": "buildpack.yml",
+					},
+				},
+				{
+					Name:    "bundler",
+					Version: "2.1.x",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry.Version).To(Equal("2.*.*, 2.x.x, 2.1.x"))
+
+			Expect(buffer.String()).To(ContainSubstring("      buildpack.yml -> \"2.*.*\"\n      <unknown>     -> \"2.x.x\"\n      <unknown>     -> \"2.1.x\"\n"))
+		})
+	})
+
+	context("when the constraints of several entries overlap", func() {
+		it("narrows the version to their intersection", func() {
+			entry, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+				{
+					Name:    "bundler",
+					Version: "2.0.x",
+				},
+				{
+					Name:    "bundler",
+					Version: "2.x.x",
+					Metadata: map[string]interface{}{
+						"version-source": "buildpack.yml",
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry).To(Equal(packit.BuildpackPlanEntry{
+				Name:    "bundler",
+				Version: "2.x.x, 2.0.x",
+				Metadata: map[string]interface{}{
+					"version-source": "buildpack.yml",
+				},
+			}))
+
+			Expect(buffer.String()).NotTo(ContainSubstring("(conflict)"))
+		})
+	})
+
+	context("when the constraints of several entries conflict", func() {
+		var entries []packit.BuildpackPlanEntry
+
+		it.Before(func() {
+			entries = []packit.BuildpackPlanEntry{
+				{
+					Name:    "bundler",
+					Version: "1.*.*",
+					Metadata: map[string]interface{}{
+						"version-source": "Gemfile.lock",
+					},
+				},
+				{
+					Name:    "bundler",
+					Version: ">= 2.1.0",
+				},
+				{
+					Name:    "bundler",
+					Version: "2.x.x",
+					Metadata: map[string]interface{}{
+						"version-source": "BP_BUNDLER_VERSION",
+					},
+				},
+			}
+		})
+
+		it("warns about the conflict and resolves the best plan entry", func() {
+			entry, err := resolver.Resolve(config, entries)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry).To(Equal(packit.BuildpackPlanEntry{
+				Name:    "bundler",
+				Version: "2.x.x, >= 2.1.0",
+				Metadata: map[string]interface{}{
+					"version-source": "BP_BUNDLER_VERSION",
+				},
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("      BP_BUNDLER_VERSION -> \"2.x.x\"\n      Gemfile.lock       -> \"1.*.*\" (conflict)\n      <unknown>          -> \">= 2.1.0\"\n"))
+			Expect(buffer.String()).To(ContainSubstring("    WARNING: version constraints conflict with \"2.x.x\" from BP_BUNDLER_VERSION:\n      \"1.*.*\" from Gemfile.lock is ignored\n"))
+		})
+
+		context("when the conflict policy is fail", func() {
+			it.Before(func() {
+				resolver = bundler.NewPlanEntryResolver(bundler.NewLogEmitter(buffer), "fail", "")
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(config, entries)
+				Expect(err).To(MatchError(`failed to satisfy version constraints: "1.*.*" from Gemfile.lock cannot be satisfied together with "2.x.x" from BP_BUNDLER_VERSION`))
+
+				Expect(buffer.String()).To(ContainSubstring("      BP_BUNDLER_VERSION -> \"2.x.x\"\n      Gemfile.lock       -> \"1.*.*\" (conflict)\n      <unknown>          -> \">= 2.1.0\"\n"))
+			})
+		})
+	})
+
+	context("when the chosen entry has no version", func() {
+		it("resolves the version from the entries of lower priority", func() {
+			resolver = bundler.NewPlanEntryResolver(bundler.NewLogEmitter(buffer), "fail", "")
+
+			entry, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+				{
+					Name:    "bundler",
+					Version: "1.17.x",
+				},
+				{
+					Name: "bundler",
+					Metadata: map[string]interface{}{
+						"version-source": "Gemfile.lock",
+						"launch":         true,
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry).To(Equal(packit.BuildpackPlanEntry{
+				Name:    "bundler",
+				Version: "1.17.x",
+				Metadata: map[string]interface{}{
+					"version-source": "Gemfile.lock",
+					"launch":         true,
+				},
+			}))
+		})
+	})
+
+	context("when buildpack.toml declares version source priorities", func() {
+		var entries []packit.BuildpackPlanEntry
+
+		it.Before(func() {
+			config.VersionSourcePriorities = map[string]int{
+				"Gemfile.lock": 5,
+				"":             1,
+			}
+
+			entries = []packit.BuildpackPlanEntry{
+				{
+					Name:    "bundler",
+					Version: "2.x.x",
+					Metadata: map[string]interface{}{
+						"version-source": "buildpack.yml",
+					},
+				},
+				{
+					Name:    "bundler",
+					Version: "2.1.x",
+				},
+				{
+					Name:    "bundler",
+					Version: "2.1.4",
+					Metadata: map[string]interface{}{
+						"version-source": "Gemfile.lock",
+					},
+				},
+			}
+		})
+
+		it("orders the entries using those priorities", func() {
+			entry, err := resolver.Resolve(config, entries)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry.Metadata["version-source"]).To(Equal("Gemfile.lock"))
+
+			Expect(buffer.String()).To(ContainSubstring("      Gemfile.lock  -> \"2.1.4\"\n      buildpack.yml -> \"2.x.x\"\n      <unknown>     -> \"2.1.x\"\n"))
+		})
+
+		context("when the priorities are overridden", func() {
+			it.Before(func() {
+				resolver = bundler.NewPlanEntryResolver(bundler.NewLogEmitter(buffer), "", "Gemfile.lock=0, =10")
+			})
+
+			it("orders the entries using the overridden priorities", func() {
+				entry, err := resolver.Resolve(config, entries)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entry.Metadata["version-source"]).To(BeNil())
+
+				Expect(buffer.String()).To(ContainSubstring("      <unknown>     -> \"2.1.x\"\n      buildpack.yml -> \"2.x.x\"\n      Gemfile.lock  -> \"2.1.4\"\n"))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the conflict policy is invalid", func() {
+			it.Before(func() {
+				resolver = bundler.NewPlanEntryResolver(bundler.NewLogEmitter(buffer), "ignore", "")
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+					{Name: "bundler"},
+				})
+				Expect(err).To(MatchError(`invalid version conflict policy "ignore": must be "fail" or "warn"`))
+			})
+		})
+
+		context("when the version constraint of the chosen entry cannot be parsed", func() {
+			it("returns an error", func() {
+				_, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+					{
+						Name:    "bundler",
+						Version: "1.17.x",
+					},
+					{
+						Name:    "bundler",
+						Version: "latest",
+						Metadata: map[string]interface{}{
+							"version-source": "BP_BUNDLER_VERSION",
+						},
+					},
+				})
+				Expect(err).To(MatchError(`failed to parse version constraint "latest" from BP_BUNDLER_VERSION`))
+			})
+		})
+
+		context("when the priority overrides are malformed", func() {
+			it.Before(func() {
+				resolver = bundler.NewPlanEntryResolver(bundler.NewLogEmitter(buffer), "", "Gemfile.lock")
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+					{Name: "bundler"},
+				})
+				Expect(err).To(MatchError(`invalid version source priority "Gemfile.lock": must be of the form source=priority`))
+			})
+		})
+
+		context("when a priority override is not a number", func() {
+			it.Before(func() {
+				resolver = bundler.NewPlanEntryResolver(bundler.NewLogEmitter(buffer), "", "Gemfile.lock=high")
+			})
+
+			it("returns an error", func() {
+				_, err := resolver.Resolve(config, []packit.BuildpackPlanEntry{
+					{Name: "bundler"},
+				})
+				Expect(err).To(MatchError(ContainSubstring(`invalid version source priority "Gemfile.lock=high"`)))
+			})
+		})
+	})
 }
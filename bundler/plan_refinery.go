@@ -1,30 +1,86 @@
 package bundler
 
 import (
+	"path/filepath"
+	"time"
+
 	"github.com/cloudfoundry/packit"
-	"github.com/cloudfoundry/packit/postal"
 )
 
-type PlanRefinery struct{}
+//go:generate faux --interface GemParser --output fakes/gem_parser.go
+type GemParser interface {
+	ParseGems(path string) ([]Gem, error)
+}
 
-func NewPlanRefinery() PlanRefinery {
-	return PlanRefinery{}
+type PlanRefinery struct {
+	gemParser GemParser
+}
+
+func NewPlanRefinery(gemParser GemParser) PlanRefinery {
+	return PlanRefinery{
+		gemParser: gemParser,
+	}
 }
 
-func (pf PlanRefinery) BillOfMaterial(dependency postal.Dependency) packit.BuildpackPlan {
-	return packit.BuildpackPlan{
-		Entries: []packit.BuildpackPlanEntry{
-			{
-				Name:    dependency.ID,
-				Version: dependency.Version,
-				Metadata: map[string]interface{}{
-					"licenses": []string{},
-					"name":     dependency.Name,
-					"sha256":   dependency.SHA256,
-					"stacks":   dependency.Stacks,
-					"uri":      dependency.URI,
-				},
-			},
+// BillOfMaterial returns a plan entry for the dependency followed by an entry
+// for every gem locked in the Gemfile.lock found in the working directory.
+func (pf PlanRefinery) BillOfMaterial(dependency Dependency, workingDir string) (packit.BuildpackPlan, error) {
+	licenses := dependency.Licenses
+	if licenses == nil {
+		licenses = []string{}
+	}
+
+	metadata := map[string]interface{}{
+		"licenses":      licenses,
+		"name":          dependency.Name,
+		"sha256":        dependency.SHA256,
+		"source":        dependency.Source,
+		"source_sha256": dependency.SourceSHA256,
+		"stacks":        dependency.Stacks,
+		"uri":           dependency.URI,
+	}
+
+	if (dependency.DeprecationDate != time.Time{}) {
+		metadata["deprecation_date"] = dependency.DeprecationDate
+	}
+
+	entries := []packit.BuildpackPlanEntry{
+		{
+			Name:     dependency.ID,
+			Version:  dependency.Version,
+			Metadata: metadata,
 		},
 	}
+
+	gems, err := pf.gemParser.ParseGems(filepath.Join(workingDir, GemfileLockSource))
+	if err != nil {
+		return packit.BuildpackPlan{}, err
+	}
+
+	for _, gem := range gems {
+		metadata := map[string]interface{}{
+			"purl":   gem.PURL(),
+			"source": gem.Source,
+		}
+
+		if gem.Platform != "" {
+			metadata["platform"] = gem.Platform
+		}
+
+		if gem.Remote != "" {
+			metadata["remote"] = gem.Remote
+		}
+
+		if gem.Revision != "" {
+			metadata["revision"] = gem.Revision
+		}
+
+		entries = append(entries, packit.BuildpackPlanEntry{
+			Name:     gem.Name,
+			Version:  gem.Version,
+			Metadata: metadata,
+		})
+	}
+
+	return packit.BuildpackPlan{Entries: entries}, nil
 }
@@ -1,9 +1,13 @@
 package bundler_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/cloudfoundry/bundler-cnb/bundler/fakes"
+	"github.com/cloudfoundry/packit"
 	"github.com/cloudfoundry/packit/postal"
 	"github.com/sclevine/spec"
 
@@ -14,34 +18,162 @@ func testPlanRefinery(t *testing.T, context spec.G, it spec.S) {
 	var (
 		Expect = NewWithT(t).Expect
 
+		gemParser    *fakes.GemParser
 		planRefinery bundler.PlanRefinery
 	)
 
 	it.Before(func() {
-		planRefinery = bundler.NewPlanRefinery()
+		gemParser = &fakes.GemParser{}
+
+		planRefinery = bundler.NewPlanRefinery(gemParser)
 	})
 
 	context("BillOfMaterial", func() {
 		it("creates a buildpack plan entry from the given dependency", func() {
-			refinedBuildPlan := planRefinery.BillOfMaterial(postal.Dependency{
-				ID:      "some-id",
-				Name:    "some-name",
-				Stacks:  []string{"some-stack"},
-				URI:     "some-uri",
-				SHA256:  "some-sha",
-				Version: "some-version",
-			})
+			refinedBuildPlan, err := planRefinery.BillOfMaterial(bundler.Dependency{
+				Dependency: postal.Dependency{
+					ID:           "some-id",
+					Name:         "some-name",
+					Stacks:       []string{"some-stack"},
+					URI:          "some-uri",
+					SHA256:       "some-sha",
+					Source:       "some-source",
+					SourceSHA256: "some-source-sha",
+					Version:      "some-version",
+				},
+				Licenses: []string{"MIT", "Ruby OR BSD-2-Clause"},
+			}, "/working-dir")
+			Expect(err).NotTo(HaveOccurred())
 			Expect(refinedBuildPlan.Entries).To(HaveLen(1))
 			Expect(refinedBuildPlan.Entries[0].Name).To(Equal("some-id"))
 			Expect(refinedBuildPlan.Entries[0].Version).To(Equal("some-version"))
 			Expect(refinedBuildPlan.Entries[0].Metadata).To(Equal(map[string]interface{}{
-				"licenses": []string{},
-				"name":     "some-name",
-				"sha256":   "some-sha",
-				"stacks":   []string{"some-stack"},
-				"uri":      "some-uri",
+				"licenses":      []string{"MIT", "Ruby OR BSD-2-Clause"},
+				"name":          "some-name",
+				"sha256":        "some-sha",
+				"source":        "some-source",
+				"source_sha256": "some-source-sha",
+				"stacks":        []string{"some-stack"},
+				"uri":           "some-uri",
 			},
 			))
 		})
+
+		context("when the dependency has no licenses", func() {
+			it("includes an empty list of licenses", func() {
+				refinedBuildPlan, err := planRefinery.BillOfMaterial(bundler.Dependency{
+					Dependency: postal.Dependency{
+						ID:      "some-id",
+						Version: "some-version",
+					},
+				}, "/working-dir")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(refinedBuildPlan.Entries).To(HaveLen(1))
+				Expect(refinedBuildPlan.Entries[0].Metadata["licenses"]).To(Equal([]string{}))
+			})
+		})
+
+		context("when the dependency has a deprecation date", func() {
+			it("includes the deprecation date", func() {
+				deprecationDate, err := time.Parse(time.RFC3339, "2021-04-01T00:00:00Z")
+				Expect(err).NotTo(HaveOccurred())
+
+				refinedBuildPlan, err := planRefinery.BillOfMaterial(bundler.Dependency{
+					Dependency: postal.Dependency{
+						ID:              "some-id",
+						Version:         "some-version",
+						DeprecationDate: deprecationDate,
+					},
+				}, "/working-dir")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(refinedBuildPlan.Entries).To(HaveLen(1))
+				Expect(refinedBuildPlan.Entries[0].Metadata["deprecation_date"]).To(Equal(deprecationDate))
+			})
+		})
+
+		context("when the app has locked gems", func() {
+			it.Before(func() {
+				gemParser.ParseGemsCall.Returns.GemSlice = []bundler.Gem{
+					{
+						Name:    "rack",
+						Version: "2.2.2",
+						Source:  "rubygems",
+						Remote:  "https://rubygems.org/",
+					},
+					{
+						Name:     "nokogiri",
+						Version:  "1.10.9",
+						Platform: "x86_64-linux",
+						Source:   "rubygems",
+						Remote:   "https://rubygems.org/",
+					},
+					{
+						Name:     "some-git-gem",
+						Version:  "0.1.0",
+						Source:   "git",
+						Remote:   "https://github.com/some-org/some-git-gem.git",
+						Revision: "some-revision",
+					},
+				}
+			})
+
+			it("adds an entry for every gem", func() {
+				refinedBuildPlan, err := planRefinery.BillOfMaterial(bundler.Dependency{
+					Dependency: postal.Dependency{
+						ID:      "some-id",
+						Version: "some-version",
+					},
+				}, "/working-dir")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(gemParser.ParseGemsCall.Receives.Path).To(Equal("/working-dir/Gemfile.lock"))
+
+				Expect(refinedBuildPlan.Entries).To(HaveLen(4))
+				Expect(refinedBuildPlan.Entries[1:]).To(Equal([]packit.BuildpackPlanEntry{
+					{
+						Name:    "rack",
+						Version: "2.2.2",
+						Metadata: map[string]interface{}{
+							"purl":   "pkg:gem/rack@2.2.2",
+							"source": "rubygems",
+							"remote": "https://rubygems.org/",
+						},
+					},
+					{
+						Name:    "nokogiri",
+						Version: "1.10.9",
+						Metadata: map[string]interface{}{
+							"purl":     "pkg:gem/nokogiri@1.10.9?platform=x86_64-linux",
+							"source":   "rubygems",
+							"platform": "x86_64-linux",
+							"remote":   "https://rubygems.org/",
+						},
+					},
+					{
+						Name:    "some-git-gem",
+						Version: "0.1.0",
+						Metadata: map[string]interface{}{
+							"purl":     "pkg:gem/some-git-gem@0.1.0?vcs_url=git%2Bhttps%3A%2F%2Fgithub.com%2Fsome-org%2Fsome-git-gem.git%40some-revision",
+							"source":   "git",
+							"remote":   "https://github.com/some-org/some-git-gem.git",
+							"revision": "some-revision",
+						},
+					},
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the Gemfile.lock cannot be parsed", func() {
+				it.Before(func() {
+					gemParser.ParseGemsCall.Returns.Error = errors.New("failed to parse Gemfile.lock")
+				})
+
+				it("returns an error", func() {
+					_, err := planRefinery.BillOfMaterial(bundler.Dependency{}, "/working-dir")
+					Expect(err).To(MatchError("failed to parse Gemfile.lock"))
+				})
+			})
+		})
 	})
 }
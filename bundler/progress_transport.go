@@ -0,0 +1,127 @@
+package bundler
+
+import (
+	"io"
+	"time"
+
+	"github.com/cloudfoundry/packit/scribe"
+)
+
+// ProgressInterval is how often the progress of a download is printed when
+// the output is not a terminal.
+const ProgressInterval = 5 * time.Second
+
+// ProgressTransport reports the size, throughput and progress of the
+// downloads made by the wrapped Transport.
+type ProgressTransport struct {
+	transport Transport
+	logger    LogEmitter
+	clock     Clock
+	terminal  bool
+}
+
+// NewProgressTransport creates a ProgressTransport that logs through the
+// given LogEmitter. When the output is a terminal a progress bar is shown,
+// otherwise the progress is printed every ProgressInterval.
+func NewProgressTransport(transport Transport, logger LogEmitter, clock Clock, terminal bool) ProgressTransport {
+	return ProgressTransport{
+		transport: transport,
+		logger:    logger,
+		clock:     clock,
+		terminal:  terminal,
+	}
+}
+
+func (t ProgressTransport) Drop(root, uri string) (io.ReadCloser, error) {
+	download, err := t.transport.Drop(root, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	size := downloadSize(download)
+	t.logger.DownloadStarted(uri, size)
+
+	now := t.clock.Now()
+	reader := &progressReader{
+		ReadCloser: download,
+		logger:     t.logger,
+		clock:      t.clock,
+		size:       size,
+		started:    now,
+		reported:   now,
+	}
+
+	// A bar can only show the progress of downloads with a known size, and
+	// would garble structured output.
+	if t.terminal && size > 0 && !t.logger.structured() {
+		reader.bar = t.logger.Bar()
+		reader.bar.Start()
+	}
+
+	return reader, nil
+}
+
+type progressReader struct {
+	io.ReadCloser
+
+	logger LogEmitter
+	clock  Clock
+	bar    *scribe.Bar
+
+	size       int64
+	downloaded int64
+	percent    int64
+	started    time.Time
+	reported   time.Time
+	done       bool
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.downloaded += int64(n)
+
+	if err == io.EOF {
+		r.finish(true)
+		return n, err
+	}
+
+	if r.bar != nil {
+		// The bar counts in percent, so it is advanced once for every percent
+		// downloaded.
+		for percent := r.downloaded * 100 / r.size; r.percent < percent && r.percent < 100; r.percent++ {
+			r.bar.Increment()
+		}
+
+		return n, err
+	}
+
+	if now := r.clock.Now(); now.Sub(r.reported) >= ProgressInterval {
+		r.reported = now
+		r.logger.DownloadProgress(r.downloaded, r.size, now.Sub(r.started))
+	}
+
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	r.finish(false)
+
+	return r.ReadCloser.Close()
+}
+
+// finish stops the bar and, when the whole download has been read, reports
+// its size and throughput.
+func (r *progressReader) finish(completed bool) {
+	if r.done {
+		return
+	}
+	r.done = true
+
+	if r.bar != nil {
+		r.bar.Finish()
+	}
+
+	if completed {
+		r.logger.DownloadCompleted(r.downloaded, r.clock.Now().Sub(r.started))
+	}
+}
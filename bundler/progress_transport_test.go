@@ -0,0 +1,152 @@
+package bundler_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/cloudfoundry/bundler-cnb/bundler/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testProgressTransport(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		buffer    *bytes.Buffer
+		now       time.Time
+		clock     bundler.Clock
+		transport *fakes.Transport
+		progress  bundler.ProgressTransport
+	)
+
+	it.Before(func() {
+		buffer = bytes.NewBuffer(nil)
+
+		// Every reading of the clock is three seconds after the previous one.
+		now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock = bundler.NewClock(func() time.Time {
+			now = now.Add(3 * time.Second)
+			return now
+		})
+
+		transport = &fakes.Transport{}
+		transport.DropCall.Returns.ReadCloser = ioutil.NopCloser(iotest.OneByteReader(strings.NewReader("some-contents")))
+
+		progress = bundler.NewProgressTransport(transport, bundler.NewLogEmitter(buffer), clock, false)
+	})
+
+	context("Drop", func() {
+		it("prints the progress of the download", func() {
+			bundle, err := progress.Drop("some-root", "some-uri")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(transport.DropCall.Receives.Root).To(Equal("some-root"))
+			Expect(transport.DropCall.Receives.Uri).To(Equal("some-uri"))
+
+			content, err := ioutil.ReadAll(bundle)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("some-contents"))
+			Expect(bundle.Close()).To(Succeed())
+
+			lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+			Expect(lines[0]).To(Equal("      Downloading some-uri"))
+			Expect(lines[1]).To(Equal("      Downloaded 2 B at 0 B/s"))
+			Expect(lines[len(lines)-1]).To(Equal("      Downloaded 13 B in 42s (0 B/s)"))
+		})
+
+		context("when the size of the download is known", func() {
+			var dir string
+
+			it.Before(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "progress")
+				Expect(err).NotTo(HaveOccurred())
+
+				path := filepath.Join(dir, "some-dependency.tgz")
+				Expect(ioutil.WriteFile(path, bytes.Repeat([]byte("a"), 4096), 0644)).To(Succeed())
+
+				file, err := os.Open(path)
+				Expect(err).NotTo(HaveOccurred())
+
+				transport.DropCall.Returns.ReadCloser = file
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(dir)).To(Succeed())
+			})
+
+			it("prints the size and the percentage downloaded", func() {
+				bundle, err := progress.Drop("some-root", "some-uri")
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = io.CopyN(ioutil.Discard, bundle, 1024)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = io.CopyN(ioutil.Discard, bundle, 1024)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = io.Copy(ioutil.Discard, bundle)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bundle.Close()).To(Succeed())
+
+				Expect(buffer.String()).To(ContainSubstring("      Downloading some-uri (4.0 KiB)\n"))
+				Expect(buffer.String()).To(ContainSubstring("      Downloaded 2.0 KiB of 4.0 KiB (50%) at 341 B/s\n"))
+				Expect(buffer.String()).To(ContainSubstring("      Downloaded 4.0 KiB in 12s (341 B/s)\n"))
+			})
+
+			context("when the output is a terminal", func() {
+				it.Before(func() {
+					progress = bundler.NewProgressTransport(transport, bundler.NewLogEmitter(buffer), clock, true)
+				})
+
+				it("shows a progress bar instead of progress lines", func() {
+					bundle, err := progress.Drop("some-root", "some-uri")
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = io.Copy(ioutil.Discard, bundle)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(bundle.Close()).To(Succeed())
+
+					Expect(buffer.String()).To(ContainSubstring("100.00%"))
+					Expect(buffer.String()).NotTo(ContainSubstring(" of 4.0 KiB"))
+					Expect(buffer.String()).To(ContainSubstring("      Downloaded 4.0 KiB in "))
+				})
+			})
+		})
+
+		context("when the download is closed before it is read", func() {
+			it("does not report it as completed", func() {
+				bundle, err := progress.Drop("some-root", "some-uri")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bundle.Close()).To(Succeed())
+
+				Expect(buffer.String()).To(Equal("      Downloading some-uri\n"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the transport fails", func() {
+				it.Before(func() {
+					transport.DropCall.Returns.Error = errors.New("failed to drop")
+				})
+
+				it("returns the error without printing anything", func() {
+					_, err := progress.Drop("some-root", "some-uri")
+					Expect(err).To(MatchError("failed to drop"))
+					Expect(buffer.String()).To(BeEmpty())
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// resolutionTrace explains how the dependencies in buildpack.toml are matched
+// against the given version, stack and Ruby, mirroring the rules applied by
+// rubyCompatibleVersion and the DependencyManager: the constraint used,
+// followed by every dependency with the given id and whether it was rejected
+// by the stack filter, the constraint or its ruby_versions range. The Ruby
+// range is not checked when the Ruby version is empty.
+func resolutionTrace(config BuildpackConfig, id, version, stack, ruby string) []string {
+	constraint := config.constraint(id, version)
+
+	trace := []string{fmt.Sprintf("Resolving %s with constraint %q on stack %s", id, constraint, stack)}
+
+	versionConstraint, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return append(trace, fmt.Sprintf("Constraint %q is invalid: %s", constraint, err))
+	}
+
+	for _, dependency := range config.Dependencies {
+		if dependency.ID != id {
+			continue
+		}
+
+		line := fmt.Sprintf("%s %s (stacks %s): ", dependency.ID, dependency.Version, strings.Join(dependency.Stacks, ", "))
+
+		v, err := semver.NewVersion(dependency.Version)
+		compatible, known := true, true
+		if ruby != "" && dependency.RubyVersions != "" {
+			compatible, known = satisfiable(dependency.RubyVersions, ruby)
+		}
+
+		switch {
+		case !stacksInclude(dependency.Stacks, stack):
+			line += fmt.Sprintf("rejected, stack %s is not supported", stack)
+		case err != nil:
+			line += "rejected, version is invalid"
+		case !versionConstraint.Check(v):
+			line += fmt.Sprintf("rejected, does not match %q", constraint)
+		case !known:
+			line += fmt.Sprintf("rejected, ruby_versions %q is invalid", dependency.RubyVersions)
+		case !compatible:
+			line += fmt.Sprintf("rejected, requires Ruby %s", dependency.RubyVersions)
+		default:
+			line += "candidate"
+		}
+
+		trace = append(trace, line)
+	}
+
+	return trace
+}
@@ -0,0 +1,355 @@
+package bundler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RetryConfig controls how RetryTransport retries downloads.
+type RetryConfig struct {
+	// Attempts is the total number of requests made for a download, including
+	// the ones that resume it.
+	Attempts int
+
+	// Backoff is the delay before the first retry. It doubles for every retry
+	// after that, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Timeout is how long a request may go without receiving any data, be it
+	// while connecting, waiting for the response or reading its body.
+	Timeout time.Duration
+}
+
+// DefaultRetryConfig is used for any setting that is not configured.
+var DefaultRetryConfig = RetryConfig{
+	Attempts:   5,
+	Backoff:    time.Second,
+	MaxBackoff: 30 * time.Second,
+	Timeout:    30 * time.Second,
+}
+
+// ParseRetryConfig returns the DefaultRetryConfig with the given number of
+// attempts and timeout, each of which is left unchanged when empty. The
+// timeout is a duration such as 1m30s.
+func ParseRetryConfig(attempts, timeout string) (RetryConfig, error) {
+	config := DefaultRetryConfig
+
+	if attempts != "" {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 1 {
+			return RetryConfig{}, fmt.Errorf("invalid number of download attempts %q: must be a positive integer", attempts)
+		}
+
+		config.Attempts = n
+	}
+
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return RetryConfig{}, fmt.Errorf("invalid download timeout %q: must be a positive duration", timeout)
+		}
+
+		config.Timeout = d
+	}
+
+	return config, nil
+}
+
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	delay := c.Backoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= c.MaxBackoff {
+			return c.MaxBackoff
+		}
+	}
+
+	return delay
+}
+
+// RetryTransport downloads http:// and https:// uris, retrying requests that
+// fail with a network error or a transient status with an exponential
+// backoff. A download that breaks off is resumed with a Range request from
+// where it stopped. Every other uri is handed to the wrapped Transport.
+type RetryTransport struct {
+	transport Transport
+	client    *http.Client
+	logger    LogEmitter
+	config    RetryConfig
+}
+
+// NewRetryTransport creates a RetryTransport that downloads with the given
+// client and logs its retries through the given LogEmitter.
+func NewRetryTransport(transport Transport, client *http.Client, logger LogEmitter, config RetryConfig) RetryTransport {
+	return RetryTransport{
+		transport: transport,
+		client:    client,
+		logger:    logger,
+		config:    config,
+	}
+}
+
+func (t RetryTransport) Drop(root, uri string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(uri, "http://") && !strings.HasPrefix(uri, "https://") {
+		return t.transport.Drop(root, uri)
+	}
+
+	download := &resumableDownload{
+		transport: t,
+		uri:       uri,
+		size:      -1,
+	}
+
+	err := download.connect(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return download, nil
+}
+
+// resumableDownload is the body of a download that reconnects when reading
+// it fails.
+type resumableDownload struct {
+	transport RetryTransport
+	uri       string
+
+	// size is the size of the whole download, and offset is the number of
+	// bytes of it that have been read.
+	size   int64
+	offset int64
+
+	// validator identifies the version of the download so that it is only
+	// resumed when it has not changed.
+	validator string
+
+	attempt  int
+	body     io.ReadCloser
+	timer    *time.Timer
+	cancel   context.CancelFunc
+	timedOut *int32
+	err      error
+}
+
+func (d *resumableDownload) Size() int64 {
+	return d.size
+}
+
+func (d *resumableDownload) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	for {
+		n, err := d.body.Read(p)
+		d.offset += int64(n)
+
+		if err == nil {
+			d.timer.Reset(d.transport.config.Timeout)
+			return n, nil
+		}
+
+		if err == io.EOF {
+			d.err = io.EOF
+			d.release()
+			return n, err
+		}
+
+		cause := d.failure("failed to read response", err)
+		d.release()
+
+		d.err = d.connect(cause)
+		if d.err != nil || n > 0 {
+			return n, d.err
+		}
+	}
+}
+
+func (d *resumableDownload) Close() error {
+	if d.body == nil {
+		return nil
+	}
+
+	return d.release()
+}
+
+// connect makes requests until one succeeds or the attempts run out. The
+// cause is the error that ended the previous attempt, if any.
+func (d *resumableDownload) connect(cause error) error {
+	config := d.transport.config
+
+	err := cause
+	for {
+		if err != nil {
+			if d.attempt >= config.Attempts {
+				return fmt.Errorf("failed to download %s after %d attempts: %w", d.uri, d.attempt, err)
+			}
+
+			delay := config.backoff(d.attempt)
+			d.transport.logger.DownloadRetry(d.uri, d.attempt, config.Attempts, err, delay)
+			time.Sleep(delay)
+		}
+
+		d.attempt++
+		d.transport.logger.Debug("Download attempt %d of %d for %s from byte %d", d.attempt, config.Attempts, d.uri, d.offset)
+
+		var retry bool
+		retry, err = d.request()
+		if err == nil {
+			return nil
+		}
+
+		if !retry {
+			return err
+		}
+	}
+}
+
+// request makes a single request for the rest of the download and reports
+// whether a failure is worth retrying.
+func (d *resumableDownload) request() (bool, error) {
+	timeout := d.transport.config.Timeout
+
+	ctx, cancel := context.WithCancel(context.Background())
+	timedOut := new(int32)
+	timer := time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(timedOut, 1)
+		cancel()
+	})
+
+	abort := func() {
+		timer.Stop()
+		cancel()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, d.uri, nil)
+	if err != nil {
+		abort()
+		return false, fmt.Errorf("failed to parse request uri: %w", err)
+	}
+
+	if d.offset > 0 {
+		d.transport.logger.DownloadResumed(d.uri, d.offset)
+
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+		if d.validator != "" {
+			request.Header.Set("If-Range", d.validator)
+		}
+	}
+
+	response, err := d.transport.client.Do(request)
+	if err != nil {
+		abort()
+		if atomic.LoadInt32(timedOut) == 1 {
+			return true, fmt.Errorf("failed to make request: no response within %s", timeout)
+		}
+
+		return true, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		response.Body.Close()
+		abort()
+		return transientStatus(response.StatusCode), fmt.Errorf("failed to make request: unexpected status %s", response.Status)
+	}
+
+	if d.offset > 0 {
+		// A server that does not support ranges sends all of it, so the part
+		// that was already read is skipped. When the If-Range validator was
+		// sent, a full response instead means the content has changed and the
+		// part that was already read belongs to a different version.
+		skip := d.offset
+		if response.StatusCode != http.StatusPartialContent && d.validator != "" {
+			response.Body.Close()
+			abort()
+			return false, errors.New("failed to resume download: content changed since download started")
+		}
+
+		if response.StatusCode == http.StatusPartialContent {
+			start, ok := contentRangeStart(response.Header.Get("Content-Range"))
+			if !ok || start > d.offset {
+				response.Body.Close()
+				abort()
+				return true, fmt.Errorf("failed to resume download: unexpected content range %q", response.Header.Get("Content-Range"))
+			}
+
+			skip = d.offset - start
+		}
+
+		_, err = io.CopyN(ioutil.Discard, response.Body, skip)
+		if err != nil {
+			response.Body.Close()
+			abort()
+			return true, fmt.Errorf("failed to resume download: %w", err)
+		}
+	} else {
+		d.size = response.ContentLength
+		d.validator = response.Header.Get("ETag")
+		if d.validator == "" {
+			d.validator = response.Header.Get("Last-Modified")
+		}
+	}
+
+	timer.Reset(timeout)
+
+	d.body = response.Body
+	d.timer = timer
+	d.cancel = cancel
+	d.timedOut = timedOut
+
+	return false, nil
+}
+
+// failure describes why reading the body failed, which is the timeout when
+// it was the timeout that canceled the request.
+func (d *resumableDownload) failure(message string, err error) error {
+	if atomic.LoadInt32(d.timedOut) == 1 {
+		return fmt.Errorf("%s: no data received within %s", message, d.transport.config.Timeout)
+	}
+
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+func (d *resumableDownload) release() error {
+	d.timer.Stop()
+	err := d.body.Close()
+	d.cancel()
+	d.body = nil
+
+	return err
+}
+
+// transientStatus reports whether a request that failed with the given
+// status might succeed when it is retried.
+func transientStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// contentRangeStart returns the first byte of a Content-Range such as
+// bytes 100-199/200.
+func contentRangeStart(contentRange string) (int64, bool) {
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(contentRange, "bytes "), "-", 2)
+	if len(parts) != 2 {
+		return 0, false
+	}
+
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return start, true
+}
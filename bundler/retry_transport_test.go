@@ -0,0 +1,344 @@
+package bundler_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/cloudfoundry/bundler-cnb/bundler/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRetryTransport(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		content   string
+		buffer    *bytes.Buffer
+		server    *httptest.Server
+		transport *fakes.Transport
+		config    bundler.RetryConfig
+		retry     bundler.RetryTransport
+
+		mutex    sync.Mutex
+		requests []*http.Request
+		handlers []http.HandlerFunc
+	)
+
+	serveContent := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", `"some-etag"`)
+		http.ServeContent(w, req, "some-dependency.tgz", time.Time{}, strings.NewReader(content))
+	}
+
+	// breakOffWithoutValidator sends the first half of the content and then
+	// drops the connection.
+	breakOffWithoutValidator := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, content[:len(content)/2])
+		w.(http.Flusher).Flush()
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}
+
+	breakOff := func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", `"some-etag"`)
+		breakOffWithoutValidator(w, req)
+	}
+
+	status := func(code int) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(code)
+		}
+	}
+
+	it.Before(func() {
+		content = strings.Repeat("some-dependency-contents", 100)
+		buffer = bytes.NewBuffer(nil)
+
+		requests = nil
+		handlers = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mutex.Lock()
+			requests = append(requests, req)
+			handler := http.HandlerFunc(serveContent)
+			if len(handlers) > 0 {
+				handler, handlers = handlers[0], handlers[1:]
+			}
+			mutex.Unlock()
+
+			handler(w, req)
+		}))
+
+		transport = &fakes.Transport{}
+		config = bundler.RetryConfig{
+			Attempts:   3,
+			Backoff:    time.Millisecond,
+			MaxBackoff: 2 * time.Millisecond,
+			Timeout:    time.Second,
+		}
+
+		retry = bundler.NewRetryTransport(transport, http.DefaultClient, bundler.NewLogEmitter(buffer), config)
+	})
+
+	it.After(func() {
+		server.Close()
+	})
+
+	context("Drop", func() {
+		it("downloads the uri and reports its size", func() {
+			bundle, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(bundle.(interface{ Size() int64 }).Size()).To(Equal(int64(len(content))))
+
+			downloaded, err := ioutil.ReadAll(bundle)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(downloaded)).To(Equal(content))
+			Expect(bundle.Close()).To(Succeed())
+
+			Expect(requests).To(HaveLen(1))
+			Expect(buffer.String()).To(BeEmpty())
+			Expect(transport.DropCall.CallCount).To(Equal(0))
+		})
+
+		context("when the uri is not an http uri", func() {
+			it.Before(func() {
+				transport.DropCall.Returns.ReadCloser = ioutil.NopCloser(strings.NewReader("some-file-contents"))
+			})
+
+			it("hands it to the wrapped transport", func() {
+				bundle, err := retry.Drop("some-root", "file://some-dependency.tgz")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bundle).To(Equal(transport.DropCall.Returns.ReadCloser))
+
+				Expect(transport.DropCall.Receives.Root).To(Equal("some-root"))
+				Expect(transport.DropCall.Receives.Uri).To(Equal("file://some-dependency.tgz"))
+				Expect(requests).To(BeEmpty())
+			})
+		})
+
+		context("when a request fails with a transient status", func() {
+			it.Before(func() {
+				handlers = []http.HandlerFunc{status(http.StatusServiceUnavailable), status(http.StatusTooManyRequests)}
+			})
+
+			it("retries it with an exponential backoff", func() {
+				bundle, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+				Expect(err).NotTo(HaveOccurred())
+
+				downloaded, err := ioutil.ReadAll(bundle)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(downloaded)).To(Equal(content))
+
+				Expect(requests).To(HaveLen(3))
+				Expect(buffer.String()).To(Equal(fmt.Sprintf(`      Attempt 1 of 3 to download %[1]s/some-dependency.tgz failed: failed to make request: unexpected status 503 Service Unavailable
+      Retrying in 1ms
+      Attempt 2 of 3 to download %[1]s/some-dependency.tgz failed: failed to make request: unexpected status 429 Too Many Requests
+      Retrying in 2ms
+`, server.URL)))
+			})
+		})
+
+		context("when every attempt fails", func() {
+			it.Before(func() {
+				handlers = []http.HandlerFunc{status(http.StatusInternalServerError), status(http.StatusBadGateway), status(http.StatusGatewayTimeout)}
+			})
+
+			it("returns the last error", func() {
+				_, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+				Expect(err).To(MatchError(fmt.Sprintf("failed to download %s/some-dependency.tgz after 3 attempts: failed to make request: unexpected status 504 Gateway Timeout", server.URL)))
+				Expect(requests).To(HaveLen(3))
+			})
+		})
+
+		context("when a request fails with a permanent status", func() {
+			it.Before(func() {
+				handlers = []http.HandlerFunc{status(http.StatusNotFound)}
+			})
+
+			it("does not retry it", func() {
+				_, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+				Expect(err).To(MatchError("failed to make request: unexpected status 404 Not Found"))
+				Expect(requests).To(HaveLen(1))
+				Expect(buffer.String()).To(BeEmpty())
+			})
+		})
+
+		context("when the server does not respond in time", func() {
+			it.Before(func() {
+				config.Timeout = 50 * time.Millisecond
+				retry = bundler.NewRetryTransport(transport, http.DefaultClient, bundler.NewLogEmitter(buffer), config)
+
+				handlers = []http.HandlerFunc{func(w http.ResponseWriter, req *http.Request) {
+					select {
+					case <-req.Context().Done():
+					case <-time.After(time.Second):
+					}
+				}}
+			})
+
+			it("retries the request", func() {
+				bundle, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+				Expect(err).NotTo(HaveOccurred())
+
+				downloaded, err := ioutil.ReadAll(bundle)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(downloaded)).To(Equal(content))
+
+				Expect(buffer.String()).To(ContainSubstring("failed: failed to make request: no response within 50ms\n"))
+			})
+		})
+
+		context("when the download breaks off", func() {
+			it.Before(func() {
+				handlers = []http.HandlerFunc{breakOff}
+			})
+
+			it("resumes it from where it stopped", func() {
+				bundle, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+				Expect(err).NotTo(HaveOccurred())
+
+				downloaded, err := ioutil.ReadAll(bundle)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(downloaded)).To(Equal(content))
+
+				Expect(requests).To(HaveLen(2))
+				Expect(requests[1].Header.Get("Range")).To(Equal(fmt.Sprintf("bytes=%d-", len(content)/2)))
+				Expect(requests[1].Header.Get("If-Range")).To(Equal(`"some-etag"`))
+
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("      Attempt 1 of 3 to download %s/some-dependency.tgz failed: failed to read response: ", server.URL)))
+				Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("      Resuming download of %s/some-dependency.tgz from byte %d\n", server.URL, len(content)/2)))
+			})
+
+			context("when the server ignores the range", func() {
+				it.Before(func() {
+					handlers = []http.HandlerFunc{breakOffWithoutValidator, func(w http.ResponseWriter, req *http.Request) {
+						fmt.Fprint(w, content)
+					}}
+				})
+
+				it("skips the part that was already read", func() {
+					bundle, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+					Expect(err).NotTo(HaveOccurred())
+
+					downloaded, err := ioutil.ReadAll(bundle)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(downloaded)).To(Equal(content))
+
+					Expect(requests[1].Header.Get("If-Range")).To(BeEmpty())
+				})
+			})
+
+			context("when the content has changed", func() {
+				it.Before(func() {
+					handlers = []http.HandlerFunc{breakOff, func(w http.ResponseWriter, req *http.Request) {
+						w.Header().Set("ETag", `"other-etag"`)
+						http.ServeContent(w, req, "some-dependency.tgz", time.Time{}, strings.NewReader(content))
+					}}
+				})
+
+				it("returns an error from Read without retrying", func() {
+					bundle, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = ioutil.ReadAll(bundle)
+					Expect(err).To(MatchError("failed to resume download: content changed since download started"))
+
+					Expect(requests).To(HaveLen(2))
+					Expect(requests[1].Header.Get("If-Range")).To(Equal(`"some-etag"`))
+				})
+			})
+
+			context("when the server stops sending data", func() {
+				it.Before(func() {
+					config.Timeout = 50 * time.Millisecond
+					retry = bundler.NewRetryTransport(transport, http.DefaultClient, bundler.NewLogEmitter(buffer), config)
+
+					handlers = []http.HandlerFunc{func(w http.ResponseWriter, req *http.Request) {
+						w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+						w.WriteHeader(http.StatusOK)
+						fmt.Fprint(w, content[:10])
+						w.(http.Flusher).Flush()
+
+						select {
+						case <-req.Context().Done():
+						case <-time.After(time.Second):
+						}
+					}}
+				})
+
+				it("resumes it after the timeout", func() {
+					bundle, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+					Expect(err).NotTo(HaveOccurred())
+
+					downloaded, err := ioutil.ReadAll(bundle)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(downloaded)).To(Equal(content))
+
+					Expect(buffer.String()).To(ContainSubstring("failed: failed to read response: no data received within 50ms\n"))
+					Expect(requests[1].Header.Get("Range")).To(Equal("bytes=10-"))
+				})
+			})
+
+			context("when the attempts run out", func() {
+				it.Before(func() {
+					handlers = []http.HandlerFunc{breakOffWithoutValidator, breakOffWithoutValidator, breakOffWithoutValidator}
+				})
+
+				it("returns an error from Read", func() {
+					bundle, err := retry.Drop("some-root", server.URL+"/some-dependency.tgz")
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = ioutil.ReadAll(bundle)
+					Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("failed to download %s/some-dependency.tgz after 3 attempts: failed to read response: ", server.URL))))
+					Expect(bundle.Close()).To(Succeed())
+				})
+			})
+		})
+	})
+
+	context("ParseRetryConfig", func() {
+		it("returns the default config", func() {
+			config, err := bundler.ParseRetryConfig("", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(bundler.DefaultRetryConfig))
+		})
+
+		it("overrides the attempts and the timeout", func() {
+			config, err := bundler.ParseRetryConfig("2", "1m30s")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Attempts).To(Equal(2))
+			Expect(config.Timeout).To(Equal(90 * time.Second))
+			Expect(config.Backoff).To(Equal(bundler.DefaultRetryConfig.Backoff))
+		})
+
+		context("failure cases", func() {
+			context("when the attempts are invalid", func() {
+				it("returns an error", func() {
+					_, err := bundler.ParseRetryConfig("0", "")
+					Expect(err).To(MatchError(`invalid number of download attempts "0": must be a positive integer`))
+				})
+			})
+
+			context("when the timeout is invalid", func() {
+				it("returns an error", func() {
+					_, err := bundler.ParseRetryConfig("", "30")
+					Expect(err).To(MatchError(`invalid download timeout "30": must be a positive duration`))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package bundler
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// rubyVersion returns the version of Ruby the app is built with and where it
+// was found: the given version of the installed Ruby, or the RUBY_VERSION
+// environment variable. The version is empty when it is not known.
+func rubyVersion(installed string) (string, string) {
+	if installed != "" {
+		return installed, "installed Ruby"
+	}
+
+	if version := os.Getenv("RUBY_VERSION"); version != "" {
+		return version, "RUBY_VERSION"
+	}
+
+	return "", ""
+}
+
+// rubyCompatibleVersion narrows the version constraint for the dependency so
+// that only versions whose ruby_versions range in buildpack.toml includes the
+// given Ruby version can be resolved. It returns the dependencies that were
+// excluded, and fails when every dependency matching the constraint is
+// incompatible.
+func rubyCompatibleVersion(config BuildpackConfig, id, version, stack, ruby string) (string, []Dependency, error) {
+	if ruby == "" {
+		return version, nil, nil
+	}
+
+	constraint := config.constraint(id, version)
+
+	versionConstraint, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var compatible, incompatible []Dependency
+	for _, dependency := range config.Dependencies {
+		if dependency.ID != id || !stacksInclude(dependency.Stacks, stack) {
+			continue
+		}
+
+		v, err := semver.NewVersion(dependency.Version)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if !versionConstraint.Check(v) {
+			continue
+		}
+
+		if dependency.RubyVersions != "" {
+			ok, known := satisfiable(dependency.RubyVersions, ruby)
+			if !known {
+				return "", nil, fmt.Errorf("failed to parse ruby_versions %q of %s %s", dependency.RubyVersions, dependency.ID, dependency.Version)
+			}
+
+			if !ok {
+				incompatible = append(incompatible, dependency)
+				continue
+			}
+		}
+
+		compatible = append(compatible, dependency)
+	}
+
+	if len(incompatible) == 0 {
+		return version, nil, nil
+	}
+
+	if len(compatible) == 0 {
+		var reasons []string
+		for _, dependency := range incompatible {
+			reasons = append(reasons, fmt.Sprintf("%s requires Ruby %s", dependency.Version, dependency.RubyVersions))
+		}
+
+		return "", incompatible, fmt.Errorf("failed to find a %s version matching %q that is compatible with Ruby %s: %s", id, constraint, ruby, strings.Join(reasons, ", "))
+	}
+
+	sort.Slice(compatible, func(i, j int) bool {
+		return semver.MustParse(compatible[i].Version).GreaterThan(semver.MustParse(compatible[j].Version))
+	})
+
+	// The highest compatible version is exactly what the dependency manager
+	// would have selected had the incompatible versions not been listed.
+	return compatible[0].Version, incompatible, nil
+}
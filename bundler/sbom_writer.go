@@ -0,0 +1,275 @@
+package bundler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	CycloneDXFormat = "cyclonedx"
+	SPDXFormat      = "spdx"
+
+	CycloneDXFile = "sbom.cdx.json"
+	SPDXFile      = "sbom.spdx.json"
+)
+
+type cycloneDXBOM struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDXMetadata    `json:"metadata"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string `json:"timestamp"`
+}
+
+type cycloneDXComponent struct {
+	Type               string                       `json:"type"`
+	BOMRef             string                       `json:"bom-ref"`
+	Name               string                       `json:"name"`
+	Version            string                       `json:"version"`
+	PURL               string                       `json:"purl"`
+	Hashes             []cycloneDXHash              `json:"hashes,omitempty"`
+	Licenses           []cycloneDXLicense           `json:"licenses,omitempty"`
+	ExternalReferences []cycloneDXExternalReference `json:"externalReferences,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXLicense struct {
+	Expression string `json:"expression"`
+}
+
+type cycloneDXExternalReference struct {
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+type SBOMWriter struct {
+	cycloneDX bool
+	spdx      bool
+}
+
+// NewSBOMWriter creates an SBOMWriter that writes every supported document
+// format except those named in disabled.
+func NewSBOMWriter(disabled []string) SBOMWriter {
+	writer := SBOMWriter{
+		cycloneDX: true,
+		spdx:      true,
+	}
+
+	for _, format := range disabled {
+		switch strings.ToLower(strings.TrimSpace(format)) {
+		case CycloneDXFormat:
+			writer.cycloneDX = false
+		case SPDXFormat:
+			writer.spdx = false
+		}
+	}
+
+	return writer
+}
+
+// Generate writes the enabled SBOM documents describing the dependency into
+// the layer, removing any document whose format has since been disabled.
+func (w SBOMWriter) Generate(dependency Dependency, layerPath string, now time.Time) error {
+	documents := []struct {
+		enabled  bool
+		file     string
+		document interface{}
+	}{
+		{w.cycloneDX, CycloneDXFile, newCycloneDXBOM(dependency, now)},
+		{w.spdx, SPDXFile, newSPDXDocument(dependency, now)},
+	}
+
+	for _, d := range documents {
+		path := filepath.Join(layerPath, d.file)
+
+		if !d.enabled {
+			err := os.Remove(path)
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", d.file, err)
+			}
+
+			continue
+		}
+
+		content, err := json.MarshalIndent(d.document, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", d.file, err)
+		}
+
+		err = ioutil.WriteFile(path, append(content, '\n'), 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", d.file, err)
+		}
+	}
+
+	return nil
+}
+
+func purl(dependency Dependency) string {
+	return fmt.Sprintf("pkg:gem/%s@%s", dependency.ID, dependency.Version)
+}
+
+func newCycloneDXBOM(dependency Dependency, now time.Time) cycloneDXBOM {
+	component := cycloneDXComponent{
+		Type:    "library",
+		BOMRef:  purl(dependency),
+		Name:    dependency.ID,
+		Version: dependency.Version,
+		PURL:    purl(dependency),
+	}
+
+	if dependency.SHA256 != "" {
+		component.Hashes = append(component.Hashes, cycloneDXHash{Alg: "SHA-256", Content: dependency.SHA256})
+	}
+
+	for _, expression := range dependency.Licenses {
+		component.Licenses = append(component.Licenses, cycloneDXLicense{Expression: expression})
+	}
+
+	if dependency.URI != "" {
+		component.ExternalReferences = append(component.ExternalReferences, cycloneDXExternalReference{
+			Type: "distribution",
+			URL:  dependency.URI,
+		})
+	}
+
+	if dependency.Source != "" {
+		component.ExternalReferences = append(component.ExternalReferences, cycloneDXExternalReference{
+			Type:    "other",
+			URL:     dependency.Source,
+			Comment: "source",
+		})
+	}
+
+	return cycloneDXBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.3",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: now.UTC().Format(time.RFC3339),
+		},
+		Components: []cycloneDXComponent{component},
+	}
+}
+
+func newSPDXDocument(dependency Dependency, now time.Time) spdxDocument {
+	pkg := spdxPackage{
+		SPDXID:           fmt.Sprintf("SPDXRef-Package-%s", dependency.ID),
+		Name:             dependency.ID,
+		VersionInfo:      dependency.Version,
+		DownloadLocation: "NOASSERTION",
+		LicenseConcluded: "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		CopyrightText:    "NOASSERTION",
+		ExternalRefs: []spdxExternalRef{
+			{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  purl(dependency),
+			},
+		},
+	}
+
+	switch {
+	case dependency.Source != "":
+		pkg.DownloadLocation = dependency.Source
+	case dependency.URI != "":
+		pkg.DownloadLocation = dependency.URI
+	}
+
+	if dependency.SHA256 != "" {
+		pkg.Checksums = append(pkg.Checksums, spdxChecksum{Algorithm: "SHA256", ChecksumValue: dependency.SHA256})
+	}
+
+	if len(dependency.Licenses) > 0 {
+		var expressions []string
+		for _, expression := range dependency.Licenses {
+			if strings.Contains(expression, " ") {
+				expression = fmt.Sprintf("(%s)", expression)
+			}
+			expressions = append(expressions, expression)
+		}
+
+		pkg.LicenseDeclared = strings.Join(expressions, " AND ")
+	}
+
+	return spdxDocument{
+		SPDXVersion:       "SPDX-2.2",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              fmt.Sprintf("%s-%s", dependency.ID, dependency.Version),
+		DocumentNamespace: fmt.Sprintf("https://github.com/cloudfoundry/bundler-cnb/spdx/%s-%s-%s", dependency.ID, dependency.Version, dependency.SHA256),
+		CreationInfo: spdxCreationInfo{
+			Created:  now.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: bundler-cnb"},
+		},
+		Packages: []spdxPackage{pkg},
+		Relationships: []spdxRelationship{
+			{
+				SPDXElementID:      "SPDXRef-DOCUMENT",
+				RelationshipType:   "DESCRIBES",
+				RelatedSPDXElement: pkg.SPDXID,
+			},
+		},
+	}
+}
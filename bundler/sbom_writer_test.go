@@ -0,0 +1,182 @@
+package bundler_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/cloudfoundry/packit/postal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testSBOMWriter(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		layerPath  string
+		dependency bundler.Dependency
+		now        time.Time
+	)
+
+	it.Before(func() {
+		var err error
+		layerPath, err = ioutil.TempDir("", "layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		dependency = bundler.Dependency{
+			Dependency: postal.Dependency{
+				ID:           "bundler",
+				Name:         "Bundler",
+				Version:      "2.1.4",
+				SHA256:       "some-sha",
+				URI:          "some-uri",
+				Source:       "some-source",
+				SourceSHA256: "some-source-sha",
+			},
+			Licenses: []string{"MIT"},
+		}
+
+		now = time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(layerPath)).To(Succeed())
+	})
+
+	context("Generate", func() {
+		it("writes CycloneDX and SPDX documents into the layer", func() {
+			err := bundler.NewSBOMWriter(nil).Generate(dependency, layerPath, now)
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := ioutil.ReadFile(filepath.Join(layerPath, "sbom.cdx.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+				"bomFormat": "CycloneDX",
+				"specVersion": "1.3",
+				"version": 1,
+				"metadata": {
+					"timestamp": "2020-04-01T12:00:00Z"
+				},
+				"components": [
+					{
+						"type": "library",
+						"bom-ref": "pkg:gem/bundler@2.1.4",
+						"name": "bundler",
+						"version": "2.1.4",
+						"purl": "pkg:gem/bundler@2.1.4",
+						"hashes": [{"alg": "SHA-256", "content": "some-sha"}],
+						"licenses": [{"expression": "MIT"}],
+						"externalReferences": [
+							{"type": "distribution", "url": "some-uri"},
+							{"type": "other", "url": "some-source", "comment": "source"}
+						]
+					}
+				]
+			}`))
+
+			content, err = ioutil.ReadFile(filepath.Join(layerPath, "sbom.spdx.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+				"spdxVersion": "SPDX-2.2",
+				"dataLicense": "CC0-1.0",
+				"SPDXID": "SPDXRef-DOCUMENT",
+				"name": "bundler-2.1.4",
+				"documentNamespace": "https://github.com/cloudfoundry/bundler-cnb/spdx/bundler-2.1.4-some-sha",
+				"creationInfo": {
+					"created": "2020-04-01T12:00:00Z",
+					"creators": ["Tool: bundler-cnb"]
+				},
+				"packages": [
+					{
+						"SPDXID": "SPDXRef-Package-bundler",
+						"name": "bundler",
+						"versionInfo": "2.1.4",
+						"downloadLocation": "some-source",
+						"filesAnalyzed": false,
+						"checksums": [{"algorithm": "SHA256", "checksumValue": "some-sha"}],
+						"licenseConcluded": "NOASSERTION",
+						"licenseDeclared": "MIT",
+						"copyrightText": "NOASSERTION",
+						"externalRefs": [
+							{
+								"referenceCategory": "PACKAGE-MANAGER",
+								"referenceType": "purl",
+								"referenceLocator": "pkg:gem/bundler@2.1.4"
+							}
+						]
+					}
+				],
+				"relationships": [
+					{
+						"spdxElementId": "SPDXRef-DOCUMENT",
+						"relationshipType": "DESCRIBES",
+						"relatedSpdxElement": "SPDXRef-Package-bundler"
+					}
+				]
+			}`))
+		})
+
+		context("when the dependency has compound licenses", func() {
+			it.Before(func() {
+				dependency.Licenses = []string{"MIT", "Ruby OR BSD-2-Clause"}
+			})
+
+			it("joins them into a single SPDX expression", func() {
+				err := bundler.NewSBOMWriter([]string{"cyclonedx"}).Generate(dependency, layerPath, now)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := ioutil.ReadFile(filepath.Join(layerPath, "sbom.spdx.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`"licenseDeclared": "MIT AND (Ruby OR BSD-2-Clause)"`))
+			})
+		})
+
+		context("when a format is disabled", func() {
+			it.Before(func() {
+				Expect(ioutil.WriteFile(filepath.Join(layerPath, "sbom.cdx.json"), []byte("{}"), 0644)).To(Succeed())
+			})
+
+			it("does not write that format and removes any stale document", func() {
+				err := bundler.NewSBOMWriter([]string{" CycloneDX "}).Generate(dependency, layerPath, now)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(filepath.Join(layerPath, "sbom.cdx.json")).NotTo(BeAnExistingFile())
+				Expect(filepath.Join(layerPath, "sbom.spdx.json")).To(BeAnExistingFile())
+			})
+		})
+
+		context("when every format is disabled", func() {
+			it("writes nothing", func() {
+				err := bundler.NewSBOMWriter([]string{"cyclonedx", "spdx"}).Generate(dependency, layerPath, now)
+				Expect(err).NotTo(HaveOccurred())
+
+				files, err := ioutil.ReadDir(layerPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the layer cannot be written to", func() {
+				it.Before(func() {
+					Expect(os.Chmod(layerPath, 0000)).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Chmod(layerPath, os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := bundler.NewSBOMWriter(nil).Generate(dependency, layerPath, now)
+					Expect(err).To(MatchError(ContainSubstring("failed to write sbom.cdx.json")))
+					Expect(err).To(MatchError(ContainSubstring("permission denied")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,116 @@
+package bundler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Binding is a service binding provided by the platform.
+type Binding struct {
+	Name    string
+	Type    string
+	Path    string
+	Entries map[string]string
+}
+
+type ServiceBindingResolver struct{}
+
+func NewServiceBindingResolver() ServiceBindingResolver {
+	return ServiceBindingResolver{}
+}
+
+// Resolve returns the bindings of the given type, sorted by name. Bindings
+// are read from $SERVICE_BINDING_ROOT using the Service Binding Specification
+// layout, or from $CNB_BINDINGS using the older CNB layout where the type is
+// stored in metadata/kind and the entries live under secret/.
+func (r ServiceBindingResolver) Resolve(typ string) ([]Binding, error) {
+	if root := os.Getenv("SERVICE_BINDING_ROOT"); root != "" {
+		return readBindings(root, typ, "type", ".")
+	}
+
+	if root := os.Getenv("CNB_BINDINGS"); root != "" {
+		return readBindings(root, typ, filepath.Join("metadata", "kind"), "secret")
+	}
+
+	return nil, nil
+}
+
+func readBindings(root, typ, typeFile, entriesDir string) ([]Binding, error) {
+	dirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read bindings: %w", err)
+	}
+
+	var bindings []Binding
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(root, dir.Name())
+
+		content, err := ioutil.ReadFile(filepath.Join(path, typeFile))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to read binding %s: %w", dir.Name(), err)
+		}
+
+		if !strings.EqualFold(strings.TrimSpace(string(content)), typ) {
+			continue
+		}
+
+		entries, err := readBindingEntries(filepath.Join(path, entriesDir))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read binding %s: %w", dir.Name(), err)
+		}
+
+		bindings = append(bindings, Binding{
+			Name:    dir.Name(),
+			Type:    typ,
+			Path:    path,
+			Entries: entries,
+		})
+	}
+
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+
+	return bindings, nil
+}
+
+func readBindingEntries(path string) (map[string]string, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]string{}
+	for _, file := range files {
+		// Hidden files are skipped as Kubernetes projects volume contents
+		// through ..data symlinks.
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") || file.Name() == "type" || file.Name() == "provider" {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(path, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		entries[file.Name()] = strings.TrimSpace(string(content))
+	}
+
+	return entries, nil
+}
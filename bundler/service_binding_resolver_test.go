@@ -0,0 +1,137 @@
+package bundler_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudfoundry/bundler-cnb/bundler"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testServiceBindingResolver(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		root     string
+		resolver bundler.ServiceBindingResolver
+	)
+
+	it.Before(func() {
+		var err error
+		root, err = ioutil.TempDir("", "bindings")
+		Expect(err).NotTo(HaveOccurred())
+
+		resolver = bundler.NewServiceBindingResolver()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(root)).To(Succeed())
+	})
+
+	context("Resolve", func() {
+		context("when SERVICE_BINDING_ROOT is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("SERVICE_BINDING_ROOT", root)).To(Succeed())
+
+				for name, typ := range map[string]string{
+					"some-binding":  "dependency-mapping",
+					"other-binding": "some-other-type",
+				} {
+					Expect(os.MkdirAll(filepath.Join(root, name), os.ModePerm)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(root, name, "type"), []byte(typ+"\n"), 0644)).To(Succeed())
+					Expect(ioutil.WriteFile(filepath.Join(root, name, "some-key"), []byte("some-value\n"), 0644)).To(Succeed())
+				}
+
+				Expect(ioutil.WriteFile(filepath.Join(root, "some-binding", "provider"), []byte("some-provider"), 0644)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "some-binding", ".hidden"), []byte("some-hidden-value"), 0644)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("SERVICE_BINDING_ROOT")).To(Succeed())
+			})
+
+			it("returns the bindings of the given type", func() {
+				bindings, err := resolver.Resolve("dependency-mapping")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bindings).To(Equal([]bundler.Binding{
+					{
+						Name: "some-binding",
+						Type: "dependency-mapping",
+						Path: filepath.Join(root, "some-binding"),
+						Entries: map[string]string{
+							"some-key": "some-value",
+						},
+					},
+				}))
+			})
+
+			context("when the binding root does not exist", func() {
+				it.Before(func() {
+					Expect(os.RemoveAll(root)).To(Succeed())
+				})
+
+				it("returns no bindings", func() {
+					bindings, err := resolver.Resolve("dependency-mapping")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(bindings).To(BeEmpty())
+				})
+			})
+
+			context("failure cases", func() {
+				context("when a binding cannot be read", func() {
+					it.Before(func() {
+						Expect(os.Chmod(filepath.Join(root, "some-binding", "some-key"), 0000)).To(Succeed())
+					})
+
+					it("returns an error", func() {
+						_, err := resolver.Resolve("dependency-mapping")
+						Expect(err).To(MatchError(ContainSubstring("failed to read binding some-binding")))
+						Expect(err).To(MatchError(ContainSubstring("permission denied")))
+					})
+				})
+			})
+		})
+
+		context("when CNB_BINDINGS is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("CNB_BINDINGS", root)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(root, "some-binding", "metadata"), os.ModePerm)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(root, "some-binding", "secret"), os.ModePerm)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "some-binding", "metadata", "kind"), []byte("dependency-mapping"), 0644)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(root, "some-binding", "secret", "some-key"), []byte("some-value"), 0644)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("CNB_BINDINGS")).To(Succeed())
+			})
+
+			it("returns the bindings of the given kind", func() {
+				bindings, err := resolver.Resolve("dependency-mapping")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bindings).To(Equal([]bundler.Binding{
+					{
+						Name: "some-binding",
+						Type: "dependency-mapping",
+						Path: filepath.Join(root, "some-binding"),
+						Entries: map[string]string{
+							"some-key": "some-value",
+						},
+					},
+				}))
+			})
+		})
+
+		context("when no binding root is set", func() {
+			it("returns no bindings", func() {
+				bindings, err := resolver.Resolve("dependency-mapping")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bindings).To(BeEmpty())
+			})
+		})
+	})
+}
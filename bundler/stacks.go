@@ -0,0 +1,78 @@
+package bundler
+
+import (
+	"github.com/Masterminds/semver"
+	"github.com/cloudfoundry/packit/postal"
+)
+
+// AnyStack in the stacks of a dependency makes it compatible with every stack,
+// as is the case for a pure Ruby gem such as Bundler.
+const AnyStack = "*"
+
+func stacksInclude(stacks []string, stack string) bool {
+	for _, s := range stacks {
+		if s == stack || s == AnyStack {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveDependency resolves the dependency for the given stack, also
+// considering the dependencies in buildpack.toml that are compatible with any
+// stack. The higher version wins, preferring the stack specific dependency
+// when both have the same version. A dependency compatible with any stack
+// records the given stack instead so that the bill of materials shows where
+// it was installed.
+func resolveDependency(dependencies DependencyManager, config BuildpackConfig, path, id, version, stack string) (postal.Dependency, error) {
+	wildcard := hasAnyStackDependency(config, id)
+
+	dependency, err := dependencies.Resolve(path, id, version, stack)
+	if !wildcard {
+		return dependency, err
+	}
+
+	anyStack, anyStackErr := dependencies.Resolve(path, id, version, AnyStack)
+	if anyStackErr != nil {
+		return dependency, err
+	}
+
+	if err == nil && !newerVersion(anyStack.Version, dependency.Version) {
+		return dependency, nil
+	}
+
+	anyStack.Stacks = []string{stack}
+
+	return anyStack, nil
+}
+
+func newerVersion(version, than string) bool {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+
+	t, err := semver.NewVersion(than)
+	if err != nil {
+		return false
+	}
+
+	return v.GreaterThan(t)
+}
+
+func hasAnyStackDependency(config BuildpackConfig, id string) bool {
+	for _, dependency := range config.Dependencies {
+		if dependency.ID != id {
+			continue
+		}
+
+		for _, stack := range dependency.Stacks {
+			if stack == AnyStack {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package bundler
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+var constraintVersionRegex = regexp.MustCompile(`[0-9xX*]+(\.[0-9xX*]+)?(\.[0-9xX*]+)?`)
+
+// satisfiable reports whether a single version can satisfy all of the given
+// semver constraints at once. The second return value is false when one of
+// the constraints cannot be parsed, in which case nothing is known about
+// their intersection.
+func satisfiable(constraints ...string) (bool, bool) {
+	var parsed []*semver.Constraints
+	for _, constraint := range constraints {
+		c, err := semver.NewConstraint(constraint)
+		if err != nil {
+			return false, false
+		}
+
+		parsed = append(parsed, c)
+	}
+
+	// A non-empty intersection of version ranges starts at one of the lower
+	// bounds of those ranges, or right after it when the bound is exclusive, so
+	// probing those versions is enough to find a version in the intersection.
+	probes := []*semver.Version{semver.MustParse("0.0.0")}
+	for _, constraint := range constraints {
+		for _, literal := range constraintVersionRegex.FindAllString(constraint, -1) {
+			literal = strings.NewReplacer("x", "0", "X", "0", "*", "0").Replace(literal)
+
+			version, err := semver.NewVersion(literal)
+			if err != nil {
+				continue
+			}
+
+			next := version.IncPatch()
+			probes = append(probes, version, &next)
+		}
+	}
+
+	for _, probe := range probes {
+		matches := true
+		for _, c := range parsed {
+			if !c.Check(probe) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return true, true
+		}
+	}
+
+	return false, true
+}
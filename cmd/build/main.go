@@ -1,21 +1,52 @@
 package main
 
 import (
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/cloudfoundry/bundler-cnb/bundler"
 	"github.com/cloudfoundry/packit"
-	"github.com/cloudfoundry/packit/cargo"
+	"github.com/cloudfoundry/packit/pexec"
 	"github.com/cloudfoundry/packit/postal"
 )
 
 func main() {
 	logEmitter := bundler.NewLogEmitter(os.Stdout)
-	entryResolver := bundler.NewPlanEntryResolver(logEmitter)
-	dependencyManager := postal.NewService(cargo.NewTransport())
-	planRefinery := bundler.NewPlanRefinery()
+	if os.Getenv("BP_LOG_FORMAT") == bundler.JSONLogFormat {
+		logEmitter = bundler.NewJSONLogEmitter(os.Stdout, "build")
+	}
+	logEmitter = logEmitter.WithLevel(os.Getenv("BP_LOG_LEVEL"))
+	entryResolver := bundler.NewPlanEntryResolver(logEmitter, os.Getenv("BP_BUNDLER_VERSION_CONFLICT"), os.Getenv("BP_BUNDLER_VERSION_SOURCE_PRIORITIES"))
 	clock := bundler.NewClock(time.Now)
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" || os.Getenv("BP_REPRODUCIBLE_BUILD") == "true" {
+		clock = bundler.NewReproducibleClock(time.Now, epoch)
+	}
+	// Build fails with the error when the retry settings are invalid.
+	retryConfig, err := bundler.ParseRetryConfig(os.Getenv(bundler.DependencyAttemptsEnv), os.Getenv(bundler.DependencyTimeoutEnv))
+	if err != nil {
+		retryConfig = bundler.DefaultRetryConfig
+	}
+	retryTransport := bundler.NewRetryTransport(bundler.NewHTTPTransport(http.DefaultClient), http.DefaultClient, logEmitter, retryConfig)
+	transport := bundler.NewMirrorTransport(bundler.NewProgressTransport(retryTransport, logEmitter, clock, isTerminal(os.Stdout)), os.Getenv("BP_DEPENDENCY_MIRROR"))
+	dependencyManager := bundler.NewGemDependencyManager(postal.NewService(transport), transport, pexec.NewExecutable("gem"))
+	installProcess := bundler.NewBundleInstallProcess(pexec.NewExecutable("ruby"))
+	planRefinery := bundler.NewPlanRefinery(bundler.NewGemfileLockParser())
+	sbomWriter := bundler.NewSBOMWriter(strings.Split(os.Getenv("BP_DISABLE_SBOM"), ","))
+	bindingResolver := bundler.NewServiceBindingResolver()
+	deprecationPolicy := bundler.NewDeprecationPolicy(os.Getenv("BP_BUNDLER_DEPRECATION_POLICY"), clock)
 
-	packit.Build(bundler.Build(entryResolver, dependencyManager, planRefinery, logEmitter, clock))
+	packit.Build(bundler.Build(entryResolver, dependencyManager, installProcess, planRefinery, sbomWriter, bindingResolver, deprecationPolicy, logEmitter, clock))
+}
+
+// isTerminal reports whether the file is a terminal, in which case download
+// progress is shown as a bar.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
 }
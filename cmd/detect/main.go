@@ -1,12 +1,19 @@
 package main
 
 import (
+	"os"
+
 	"github.com/cloudfoundry/bundler-cnb/bundler"
 	"github.com/cloudfoundry/packit"
 )
 
 func main() {
+	logEmitter := bundler.NewLogEmitter(os.Stdout)
+	if os.Getenv("BP_LOG_FORMAT") == bundler.JSONLogFormat {
+		logEmitter = bundler.NewJSONLogEmitter(os.Stdout, "detect")
+	}
 	buildpackYMLParser := bundler.NewBuildpackYMLParser()
+	gemfileLockParser := bundler.NewGemfileLockParser()
 
-	packit.Detect(bundler.Detect(buildpackYMLParser))
+	packit.Detect(bundler.Detect(buildpackYMLParser, gemfileLockParser, logEmitter))
 }
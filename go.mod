@@ -1,6 +1,8 @@
 module github.com/cloudfoundry/bundler-cnb
 
 require (
+	github.com/BurntSushi/toml v0.3.1
+	github.com/Masterminds/semver v1.5.0
 	github.com/buildpack/libbuildpack v1.25.11 // indirect
 	github.com/cloudfoundry/dagger v0.0.0-20200213200846-c2a9723f08c4
 	github.com/cloudfoundry/libcfbuildpack v1.91.23 // indirect